@@ -0,0 +1,172 @@
+package btsite
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// bencode 嵌套的最大深度，v2 种子的 file tree 按目录嵌套，正常种子远小于该值
+const bencodeMaxDepth = 256
+
+// bencodeDecoder bencode 解码器，整数解码为 int64，字符串为 string，列表为 []any，字典为 map[string]any。
+// 解码时记录顶层字典 info 的原始字节，用于计算 info-hash。
+type bencodeDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+	info  []byte
+}
+
+// decodeBencode 解码 bencode 数据，数据必须完整且没有多余的字节
+func decodeBencode(data []byte) (any, *bencodeDecoder, error) {
+	d := &bencodeDecoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, d, err
+	}
+	if d.pos != len(data) {
+		return nil, d, fmt.Errorf("位置 %d 存在多余的数据", d.pos)
+	}
+	return v, d, nil
+}
+
+func (d *bencodeDecoder) value() (any, error) {
+	if d.pos >= len(d.data) {
+		return nil, errors.New("数据不完整")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		return d.int()
+	case c == 'l':
+		return d.list()
+	case c == 'd':
+		return d.dict()
+	case c >= '0' && c <= '9':
+		return d.string()
+	default:
+		return nil, fmt.Errorf("位置 %d 的字符 %q 不合法", d.pos, c)
+	}
+}
+
+// int 整数 i<数字>e，不允许前导 0 和 -0
+func (d *bencodeDecoder) int() (int64, error) {
+	start := d.pos
+	end := d.index('e', start+1)
+	if end < 0 {
+		return 0, fmt.Errorf("位置 %d 的整数没有结束符", start)
+	}
+	s := string(d.data[start+1 : end])
+	digits := s
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if digits == "" || (len(digits) > 1 && digits[0] == '0') || s == "-0" {
+		return 0, fmt.Errorf("位置 %d 的整数不合法: %q", start, s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("位置 %d 的整数不合法: %q", start, s)
+	}
+	d.pos = end + 1
+	return n, nil
+}
+
+// string 字符串 <长度>:<内容>
+func (d *bencodeDecoder) string() (string, error) {
+	start := d.pos
+	colon := d.index(':', start)
+	if colon < 0 {
+		return "", fmt.Errorf("位置 %d 的字符串没有长度分隔符", start)
+	}
+	s := string(d.data[start:colon])
+	if len(s) > 1 && s[0] == '0' {
+		return "", fmt.Errorf("位置 %d 的字符串长度不合法: %q", start, s)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > len(d.data)-colon-1 {
+		return "", fmt.Errorf("位置 %d 的字符串长度不合法: %q", start, s)
+	}
+	d.pos = colon + 1 + n
+	return string(d.data[colon+1 : d.pos]), nil
+}
+
+func (d *bencodeDecoder) list() ([]any, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	d.pos++
+	list := make([]any, 0)
+	for {
+		if d.pos >= len(d.data) {
+			return nil, errors.New("列表不完整")
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return list, nil
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+}
+
+// dict 字典的 key 必须为字符串，顶层字典的 info 记录原始字节
+func (d *bencodeDecoder) dict() (map[string]any, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	top := d.depth == 1
+	d.pos++
+	dict := make(map[string]any)
+	for {
+		if d.pos >= len(d.data) {
+			return nil, errors.New("字典不完整")
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return dict, nil
+		}
+		if c := d.data[d.pos]; c < '0' || c > '9' {
+			return nil, fmt.Errorf("位置 %d 的字典 key 不是字符串", d.pos)
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		start := d.pos
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		if top && key == "info" {
+			d.info = d.data[start:d.pos]
+		}
+		dict[key] = v
+	}
+}
+
+func (d *bencodeDecoder) enter() error {
+	d.depth++
+	if d.depth > bencodeMaxDepth {
+		return fmt.Errorf("嵌套超过 %d 层", bencodeMaxDepth)
+	}
+	return nil
+}
+
+func (d *bencodeDecoder) leave() {
+	d.depth--
+}
+
+func (d *bencodeDecoder) index(c byte, from int) int {
+	for i := from; i < len(d.data); i++ {
+		if d.data[i] == c {
+			return i
+		}
+	}
+	return -1
+}
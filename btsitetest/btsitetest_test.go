@@ -0,0 +1,145 @@
+package btsitetest_test
+
+import (
+	"fmt"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-btsite/btsitetest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	rsp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rsp.StatusCode, string(b)
+}
+
+func TestRecordReplay(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		if r.URL.Path != "/rss" && r.Header.Get("Cookie") != "session=secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Set-Cookie", "session=rotated")
+		if r.Method == http.MethodPost {
+			b, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "posted %s", b)
+			return
+		}
+		fmt.Fprintf(w, "%s %s #%d", r.URL.Path, r.URL.Query().Get("q"), n)
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	request := func(t *testing.T, site *btsite.Site) []string {
+		var bodies []string
+		for _, u := range []string{site.Domain + "search?q=a&page=1", site.Domain + "search?page=1&q=a"} {
+			req, _ := http.NewRequest(http.MethodGet, u, nil)
+			req.Header.Set("Cookie", "session=secret")
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(rsp.Body)
+			rsp.Body.Close()
+			bodies = append(bodies, string(b))
+		}
+		req, _ := http.NewRequest(http.MethodPost, site.Domain+"sign", strings.NewReader("formhash=1"))
+		req.Header.Set("Cookie", "session=secret")
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		bodies = append(bodies, string(b))
+		_, rss := get(t, &http.Client{Transport: site.Transport}, upstream.URL+"/rss?passkey=x")
+		return append(bodies, rss)
+	}
+	var recorded []string
+	t.Run("record", func(t *testing.T) {
+		site := &btsite.Site{Domain: upstream.URL + "/"}
+		btsitetest.Start(t, btsitetest.ModeRecord, path, site)
+		recorded = request(t, site)
+	})
+	upstream.Close()
+	t.Run("replay", func(t *testing.T) {
+		site := &btsite.Site{}
+		btsitetest.Start(t, btsitetest.ModeReplay, path, site)
+		replayed := request(t, site)
+		if strings.Join(replayed, "|") != strings.Join(recorded, "|") {
+			t.Errorf("replayed %q, recorded %q", replayed, recorded)
+		}
+	})
+	want := []string{"/search a #1", "/search a #2", "posted formhash=1", "/rss  #4"}
+	if strings.Join(recorded, "|") != strings.Join(want, "|") {
+		t.Errorf("recorded %q, want %q", recorded, want)
+	}
+	c, err := btsitetest.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range c.Interactions {
+		if _, ok := it.Response.Header["Set-Cookie"]; ok {
+			t.Errorf("Set-Cookie recorded: %+v", it)
+		}
+	}
+}
+
+func TestReplayerUnmatched(t *testing.T) {
+	rep := btsitetest.NewReplayer(&btsitetest.Cassette{}, btsitetest.UpstreamDomain)
+	srv := httptest.NewServer(rep)
+	defer srv.Close()
+	if code, _ := get(t, srv.Client(), srv.URL+"/missing?id=1"); code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", code)
+	}
+	if unmatched := rep.Unmatched(); len(unmatched) != 1 || unmatched[0].URL != "/missing?id=1" {
+		t.Errorf("unmatched = %+v", unmatched)
+	}
+}
+
+func TestRecordScrubsSecrets(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"username": "alice", "id": 1024, "passkey": "p1", "api_token": "t1", `+
+			`"link": "download.php?id=1&passkey=p1&authkey=a1", "other": "alice2 11024"}`)
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	t.Run("record", func(t *testing.T) {
+		site := &btsite.Site{Domain: upstream.URL + "/", ApiToken: "t1"}
+		cassette := btsitetest.Start(t, btsitetest.ModeRecord, path, site)
+		cassette.Redact("alice", "btsite")
+		cassette.Redact("1024", "10000")
+		get(t, http.DefaultClient, site.Domain+"userdetails.php?id=1024&torrent_pass=x1")
+	})
+	c, err := btsitetest.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 1 {
+		t.Fatalf("got %d interactions, want 1", len(c.Interactions))
+	}
+	it := c.Interactions[0]
+	if want := "/userdetails.php?id=10000&torrent_pass=REDACTED"; it.Request.URL != want {
+		t.Errorf("url = %s, want %s", it.Request.URL, want)
+	}
+	want := `{"username": "btsite", "id": 10000, "passkey": "REDACTED", "api_token": "REDACTED", ` +
+		`"link": "download.php?id=1&passkey=REDACTED&authkey=REDACTED", "other": "alice2 11024"}`
+	if it.Response.Body != want {
+		t.Errorf("body = %s, want %s", it.Response.Body, want)
+	}
+}
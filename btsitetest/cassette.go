@@ -0,0 +1,288 @@
+// Package btsitetest 站点适配的录制回放测试工具。
+//
+// 录制模式下启动反向代理，将站点的 Domain、Api 指向代理，请求转发到真实站点并保存到 cassette 文件；
+// 回放模式下启动本地服务，按请求方法、路径、参数和请求体从 cassette 返回录制的响应，无需网络。
+// 站点适配请求由 siteadapt 发起，无法注入 Transport，所以通过替换地址实现；
+// 本包直接发起的请求（如 RSS）也可以使用 Cassette.Transport 回放。
+package btsitetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// Cassette 录制的请求响应集合
+	Cassette struct {
+		Interactions []Interaction `json:"interactions"`
+
+		mu      sync.Mutex
+		played  map[string]int    // 相同请求已回放的次数
+		secrets map[string]string // 保存前替换的文本，key 为原文
+	}
+	// Interaction 一次请求响应
+	Interaction struct {
+		Upstream string   `json:"upstream"` // 上游名称，如 domain、api
+		Request  Request  `json:"request"`
+		Response Response `json:"response"`
+	}
+	// Request 录制的请求，不保存请求头，避免泄露 Cookie 等凭证
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"` // 路径和参数，不包含协议和域名
+		Body   string `json:"body,omitempty"`
+	}
+	// Response 录制的响应
+	Response struct {
+		StatusCode int               `json:"status_code"`
+		Header     map[string]string `json:"header,omitempty"`
+		Body       string            `json:"body"`
+	}
+)
+
+// Redacted 替换凭证参数值的文本
+const Redacted = "REDACTED"
+
+// 需要保存的响应头，Set-Cookie 等不保存
+var recordedHeaders = []string{"Content-Type", "Location"}
+
+var (
+	// 地址参数形式的凭证，如 passkey=xxx、authkey=xxx
+	secretParamRegexp = regexp.MustCompile(`(?i)\b(passkey|api_token|authkey|torrent_pass)=([^&"'\s<>#]+)`)
+	// JSON 字段形式的凭证，如 "passkey": "xxx"
+	secretFieldRegexp = regexp.MustCompile(`(?i)"(passkey|api_token|authkey|torrent_pass)"(\s*:\s*)"[^"]*"`)
+)
+
+// LoadCassette 读取 cassette 文件
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("解析 cassette 失败: %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Redact 保存前将请求和响应中的 value 替换为 replacement，按完整单词匹配，用于去除用户名、用户 id 等信息，value 为空时忽略。
+// 回放时请求需要使用替换后的值才能匹配
+func (c *Cassette) Redact(value string, replacement string) {
+	if value == "" || value == replacement {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.secrets == nil {
+		c.secrets = make(map[string]string)
+	}
+	c.secrets[value] = replacement
+}
+
+// Save 保存到 cassette 文件，目录不存在时自动创建。
+// 保存前将 passkey、api_token、authkey、torrent_pass 参数的值替换为 Redacted，并替换 Redact 设置的文本
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	c.scrub()
+	b, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// scrub 去除请求地址、请求体、响应体和 Location 中的凭证，调用方需要持有锁
+func (c *Cassette) scrub() {
+	// 长的文本先替换，避免被其中包含的短文本替换
+	values := make([]string, 0, len(c.secrets))
+	for value := range c.secrets {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	replacers := make([]func(string) string, 0, len(values))
+	for _, value := range values {
+		re := regexp.MustCompile(`(^|[^\pL\pN_])` + regexp.QuoteMeta(value) + `($|[^\pL\pN_])`)
+		replacement := c.secrets[value]
+		replacers = append(replacers, func(s string) string {
+			// 相邻的两处匹配共用分隔符，替换两次
+			for i := 0; i < 2; i++ {
+				s = re.ReplaceAllString(s, "${1}"+strings.ReplaceAll(replacement, "$", "$$")+"${2}")
+			}
+			return s
+		})
+	}
+	scrub := func(s string) string {
+		s = secretParamRegexp.ReplaceAllString(s, "${1}="+Redacted)
+		s = secretFieldRegexp.ReplaceAllString(s, `"${1}"${2}"`+Redacted+`"`)
+		for _, replace := range replacers {
+			s = replace(s)
+		}
+		return s
+	}
+	for i := range c.Interactions {
+		it := &c.Interactions[i]
+		it.Request.URL = scrub(it.Request.URL)
+		it.Request.Body = scrub(it.Request.Body)
+		it.Response.Body = scrub(it.Response.Body)
+		if location, ok := it.Response.Header["Location"]; ok {
+			it.Response.Header["Location"] = scrub(location)
+		}
+	}
+}
+
+// record 追加一次请求响应
+func (c *Cassette) record(upstream string, req Request, rsp *http.Response, body []byte) {
+	header := make(map[string]string)
+	for _, name := range recordedHeaders {
+		if v := rsp.Header.Get(name); v != "" {
+			header[name] = v
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, Interaction{
+		Upstream: upstream,
+		Request:  req,
+		Response: Response{
+			StatusCode: rsp.StatusCode,
+			Header:     header,
+			Body:       string(body),
+		},
+	})
+}
+
+// match 查找录制的响应，相同请求按录制顺序依次返回，超出录制次数时返回最后一次
+func (c *Cassette) match(upstream string, req Request) (Response, bool) {
+	key := upstream + " " + req.key()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matched []Response
+	for _, it := range c.Interactions {
+		if it.Upstream == upstream && it.Request.key() == req.key() {
+			matched = append(matched, it.Response)
+		}
+	}
+	if len(matched) == 0 {
+		return Response{}, false
+	}
+	if c.played == nil {
+		c.played = make(map[string]int)
+	}
+	i := c.played[key]
+	c.played[key]++
+	if i >= len(matched) {
+		i = len(matched) - 1
+	}
+	return matched[i], true
+}
+
+// Transport 回放 cassette 的 RoundTripper，可用于 Site.Transport，未录制的请求返回异常
+func (c *Cassette) Transport(upstream string) http.RoundTripper {
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		req, err := newRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		rsp, ok := c.match(upstream, req)
+		if !ok {
+			return nil, fmt.Errorf("btsitetest: 未录制的请求: %s %s", req.Method, req.URL)
+		}
+		header := make(http.Header)
+		for k, v := range rsp.Header {
+			header.Set(k, v)
+		}
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", rsp.StatusCode, http.StatusText(rsp.StatusCode)),
+			StatusCode: rsp.StatusCode,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(rsp.Body)),
+			Request:    r,
+		}, nil
+	})
+}
+
+// recordTransport 录制经过 transport 的请求，transport 为空时使用 http.DefaultTransport
+func (c *Cassette) recordTransport(upstream string, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		req, err := newRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := transport.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(rsp.Body)
+		_ = rsp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		c.record(upstream, req, rsp, body)
+		rsp.Body = io.NopCloser(bytes.NewReader(body))
+		return rsp, nil
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newRequest 读取请求，请求体读取后会重新设置，不影响后续转发
+func newRequest(r *http.Request) (Request, error) {
+	req := Request{Method: r.Method, URL: r.URL.RequestURI()}
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return req, err
+		}
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		req.Body = string(b)
+	}
+	return req, nil
+}
+
+// key 匹配请求使用的 key，参数按名称排序，与参数顺序无关，凭证参数按 Redacted 匹配
+func (r Request) key() string {
+	path, query, _ := strings.Cut(secretParamRegexp.ReplaceAllString(r.URL, "${1}="+Redacted), "?")
+	if values, err := url.ParseQuery(query); err == nil {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		for _, k := range keys {
+			vs := append([]string(nil), values[k]...)
+			sort.Strings(vs)
+			for _, v := range vs {
+				b.WriteString("&" + url.QueryEscape(k) + "=" + url.QueryEscape(v))
+			}
+		}
+		query = b.String()
+	}
+	return r.Method + " " + path + "?" + query + " " + secretParamRegexp.ReplaceAllString(r.Body, "${1}="+Redacted)
+}
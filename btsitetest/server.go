@@ -0,0 +1,191 @@
+package btsitetest
+
+import (
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Mode 录制回放模式
+type Mode int
+
+const (
+	ModeReplay Mode = iota // 回放，未录制的请求返回 404 并标记测试失败
+	ModeRecord             // 录制，请求转发到真实站点并保存
+)
+
+// 上游名称
+const (
+	UpstreamDomain = "domain"
+	UpstreamApi    = "api"
+	UpstreamDirect = "direct" // 本包直接发起的请求，如 RSS，通过 Site.Transport 录制回放
+)
+
+// ModeFromEnv 环境变量 GO_BTSITE_RECORD 不为空时为录制模式，否则为回放模式
+func ModeFromEnv() Mode {
+	if os.Getenv("GO_BTSITE_RECORD") != "" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Recorder 录制请求的反向代理
+type Recorder struct {
+	cassette  *Cassette
+	upstream  string
+	target    *url.URL
+	transport http.RoundTripper
+}
+
+// NewRecorder 创建反向代理，请求转发到 target 并追加到 cassette，transport 为空时使用 http.DefaultTransport
+func NewRecorder(cassette *Cassette, upstream string, target string, transport http.RoundTripper) (*Recorder, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("btsitetest: 上游地址必须包含协议和域名: " + target)
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{cassette: cassette, upstream: upstream, target: u, transport: transport}, nil
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := newRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.URL.Scheme = rec.target.Scheme
+	out.URL.Host = rec.target.Host
+	out.URL.Path = strings.TrimSuffix(rec.target.Path, "/") + r.URL.Path
+	out.Host = rec.target.Host
+	// 不接受压缩，保存可读的响应体
+	out.Header.Del("Accept-Encoding")
+	rsp, err := rec.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	rec.cassette.record(rec.upstream, req, rsp, body)
+	for _, name := range recordedHeaders {
+		if v := rsp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	w.WriteHeader(rsp.StatusCode)
+	_, _ = w.Write(body)
+}
+
+// Replayer 回放请求的服务
+type Replayer struct {
+	cassette *Cassette
+	upstream string
+
+	mu        sync.Mutex
+	unmatched []Request
+}
+
+// NewReplayer 创建回放服务
+func NewReplayer(cassette *Cassette, upstream string) *Replayer {
+	return &Replayer{cassette: cassette, upstream: upstream}
+}
+
+func (rep *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := newRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rsp, ok := rep.cassette.match(rep.upstream, req)
+	if !ok {
+		rep.mu.Lock()
+		rep.unmatched = append(rep.unmatched, req)
+		rep.mu.Unlock()
+		http.Error(w, "btsitetest: 未录制的请求", http.StatusNotFound)
+		return
+	}
+	for k, v := range rsp.Header {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(rsp.StatusCode)
+	_, _ = io.WriteString(w, rsp.Body)
+}
+
+// Unmatched 未录制的请求
+func (rep *Replayer) Unmatched() []Request {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	return append([]Request(nil), rep.unmatched...)
+}
+
+// Start 根据模式启动录制或回放服务，并将 site 的 Domain、Api 指向本地服务，Transport 替换为录制回放的 Transport，返回使用的 cassette。
+// 录制模式下 site 需要配置真实的 Domain，Api 为空时不代理，测试结束后去除凭证并保存 cassette，site.ApiToken 会被替换，
+// 用户名等其他信息可以通过 Cassette.Redact 替换；
+// 回放模式下 cassette 不存在时测试失败，测试结束后存在未录制的请求时标记测试失败。
+func Start(t testing.TB, mode Mode, path string, site *btsite.Site) *Cassette {
+	t.Helper()
+	upstreams := map[string]*string{UpstreamDomain: &site.Domain}
+	if site.Api != "" || mode == ModeReplay {
+		upstreams[UpstreamApi] = &site.Api
+	}
+	switch mode {
+	case ModeRecord:
+		cassette := &Cassette{}
+		for name, addr := range upstreams {
+			rec, err := NewRecorder(cassette, name, *addr, site.Transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewServer(rec)
+			t.Cleanup(srv.Close)
+			*addr = srv.URL + "/"
+		}
+		site.Transport = cassette.recordTransport(UpstreamDirect, site.Transport)
+		cassette.Redact(site.ApiToken, Redacted)
+		t.Cleanup(func() {
+			if err := cassette.Save(path); err != nil {
+				t.Error(err)
+			}
+		})
+		return cassette
+	default:
+		cassette, err := LoadCassette(path)
+		if errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("cassette 不存在，设置 GO_BTSITE_RECORD=1 录制: %s", path)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, addr := range upstreams {
+			rep := NewReplayer(cassette, name)
+			srv := httptest.NewServer(rep)
+			t.Cleanup(func() {
+				srv.Close()
+				for _, req := range rep.Unmatched() {
+					t.Errorf("未录制的请求: %s %s", req.Method, req.URL)
+				}
+			})
+			*addr = srv.URL + "/"
+		}
+		site.Transport = cassette.Transport(UpstreamDirect)
+		return cassette
+	}
+}
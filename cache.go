@@ -0,0 +1,172 @@
+package btsite
+
+import (
+	lru "container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+type (
+	// Cache 响应缓存，默认提供内存 LRU 实现，可以自行实现 Redis 等外部存储
+	Cache interface {
+		// Get 获取缓存，不存在或已过期时返回 false
+		Get(key string) ([]byte, bool)
+		// Set 设置缓存，ttl 后过期
+		Set(key string, value []byte, ttl time.Duration)
+	}
+	// LRUCache 带过期时间的内存 LRU 缓存
+	LRUCache struct {
+		mu       sync.Mutex
+		capacity int
+		ll       *lru.List
+		items    map[string]*lru.Element
+	}
+	lruEntry struct {
+		key      string
+		value    []byte
+		expireAt time.Time
+	}
+	// responseCache 单个请求的缓存
+	responseCache struct {
+		cache  Cache
+		key    string
+		ttl    time.Duration
+		bypass bool
+	}
+	bypassCacheKey struct{}
+)
+
+// NewLRUCache 创建内存 LRU 缓存，capacity 为最大缓存条数
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		ll:       lru.New(),
+		items:    make(map[string]*lru.Element),
+	}
+}
+
+// Get 获取缓存，过期的缓存会被删除
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if time.Now().After(entry.expireAt) {
+		c.ll.Remove(e)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return entry.value, true
+}
+
+// Set 设置缓存，超出容量时淘汰最久未使用的缓存
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expireAt := time.Now().Add(ttl)
+	if e, ok := c.items[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.value, entry.expireAt = value, expireAt
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expireAt: expireAt})
+	for c.capacity > 0 && c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// WithoutCache 返回不读取缓存的 ctx，请求结果仍会写入缓存，用于强制刷新
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// SetCache 设置响应缓存，为空时不缓存。
+// 只缓存站点配置 cache 中配置了缓存时间的请求，签到等非幂等请求不会缓存。
+func (r *Registry) SetCache(cache Cache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = cache
+}
+
+// cacheFor 获取请求的缓存，请求不可缓存时返回 nil
+func cacheFor(params requestSiteParams) *responseCache {
+	if params.reqId == "" || nonIdempotentRequests[params.reqId] {
+		return nil
+	}
+	r := registryOf(params.site)
+	r.mu.RLock()
+	cache := r.cache
+	r.mu.RUnlock()
+	if cache == nil {
+		return nil
+	}
+	sc, err := siteConfig(params.site)
+	if err != nil {
+		return nil
+	}
+	seconds := sc.Cache[string(params.reqId)]
+	if seconds <= 0 {
+		return nil
+	}
+	site := params.site
+	// 区分账号，避免不同账号的数据串用
+	b, err := json.Marshal([]any{site.UserId, site.Cookie, site.Domain, site.Api, params.path,
+		params.params, params.formData, params.env, params.body})
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(b)
+	bypass := false
+	if params.ctx != nil {
+		bypass, _ = params.ctx.Value(bypassCacheKey{}).(bool)
+	}
+	return &responseCache{
+		cache:  cache,
+		key:    "btsite:" + site.Code + ":" + string(params.reqId) + ":" + hex.EncodeToString(sum[:]),
+		ttl:    time.Duration(seconds) * time.Second,
+		bypass: bypass,
+	}
+}
+
+// get 获取缓存的原始数据
+func (rc *responseCache) get() ([]byte, bool) {
+	if rc == nil || rc.bypass {
+		return nil, false
+	}
+	return rc.cache.Get(rc.key)
+}
+
+// set 缓存原始数据
+func (rc *responseCache) set(value []byte) {
+	if rc == nil {
+		return
+	}
+	rc.cache.Set(rc.key, value, rc.ttl)
+}
+
+// load 从缓存解析 output
+func (rc *responseCache) load(output any) bool {
+	b, ok := rc.get()
+	return ok && json.Unmarshal(b, output) == nil
+}
+
+// store 缓存 output
+func (rc *responseCache) store(output any) {
+	if rc == nil {
+		return
+	}
+	if b, err := json.Marshal(output); err == nil {
+		rc.set(b)
+	}
+}
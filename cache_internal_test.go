@@ -0,0 +1,43 @@
+package btsite
+
+import (
+	"github.com/heibizi/go-siteadapt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNonIdempotentNotCached(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	reqIds := []requestId{requestIdSignIn, requestIdMarkAsRead, requestIdMTGenDLToken}
+	rds := make(map[string]siteadapt.RequestDefinition)
+	cache := make(map[string]int)
+	for _, reqId := range reqIds {
+		rds[string(reqId)] = siteadapt.RequestDefinition{Path: string(reqId)}
+		cache[string(reqId)] = 60
+	}
+	r := NewRegistry(&AdaptCfg{Configs: []Config{{
+		Config: siteadapt.Config{ID: "cache", Domain: srv.URL, RequestDefinitions: rds},
+		Schema: string(siteSchemaNexusPHP),
+		Cache:  cache,
+	}}})
+	r.SetCache(NewLRUCache(10))
+	site := &Site{Code: "cache", registry: r}
+	for _, reqId := range reqIds {
+		hits.Store(0)
+		for i := 0; i < 2; i++ {
+			if err := raw(requestSiteParams{site: site, reqId: reqId}, func(siteadapt.RawResult) {}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := hits.Load(); got != 2 {
+			t.Errorf("%s: hits = %d, want 2 without cache", reqId, got)
+		}
+	}
+}
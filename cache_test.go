@@ -0,0 +1,104 @@
+package btsite_test
+
+import (
+	"context"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-siteadapt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := btsite.NewLRUCache(2)
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), time.Minute)
+	c.Get("a")
+	c.Set("c", []byte("3"), time.Minute)
+	if _, ok := c.Get("b"); ok {
+		t.Error("Get(b) ok = true, want evicted")
+	}
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("Get(a) = %s, %v, want 1, true", v, ok)
+	}
+	c.Set("d", []byte("4"), -time.Second)
+	if _, ok := c.Get("d"); ok {
+		t.Error("Get(d) ok = true, want expired")
+	}
+}
+
+// newCacheSite 创建 favicon 缓存 60 秒的站点，返回注册表和请求次数
+func newCacheSite(t *testing.T) (*btsite.Registry, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		_, _ = w.Write([]byte(strconv.Itoa(int(n))))
+	}))
+	t.Cleanup(srv.Close)
+	r := btsite.NewRegistry(&btsite.AdaptCfg{Configs: []btsite.Config{{
+		Config: siteadapt.Config{ID: "cache", Domain: srv.URL, RequestDefinitions: map[string]siteadapt.RequestDefinition{
+			"favicon": {Path: "favicon.ico"},
+		}},
+		Schema: "NexusPHP",
+		Cache:  map[string]int{"favicon": 60},
+	}}})
+	r.SetCache(btsite.NewLRUCache(10))
+	return r, &hits
+}
+
+func favicon(t *testing.T, c btsite.ClientContext, ctx context.Context) string {
+	t.Helper()
+	icon, err := c.Favicon(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(icon)
+}
+
+func TestClientCache(t *testing.T) {
+	r, hits := newCacheSite(t)
+	c, err := r.NewClientContext(&btsite.Site{Code: "cache", Cookie: "uid=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if got := favicon(t, c, ctx); got != "1" {
+		t.Errorf("Favicon() = %s, want 1", got)
+	}
+	if got := favicon(t, c, ctx); got != "1" || hits.Load() != 1 {
+		t.Errorf("Favicon() = %s, hits = %d, want cached 1", got, hits.Load())
+	}
+	// WithoutCache 不读取缓存，结果写入缓存
+	if got := favicon(t, c, btsite.WithoutCache(ctx)); got != "2" {
+		t.Errorf("Favicon(WithoutCache) = %s, want 2", got)
+	}
+	if got := favicon(t, c, ctx); got != "2" || hits.Load() != 2 {
+		t.Errorf("Favicon() = %s, hits = %d, want refreshed cache 2", got, hits.Load())
+	}
+}
+
+func TestClientCacheKeyPerAccount(t *testing.T) {
+	r, hits := newCacheSite(t)
+	ctx := context.Background()
+	for i, site := range []btsite.Site{
+		{Code: "cache", UserId: "1", Cookie: "uid=1"},
+		{Code: "cache", UserId: "2", Cookie: "uid=2"},
+		{Code: "cache", UserId: "1", Cookie: "uid=1"},
+	} {
+		c, err := r.NewClientContext(&site)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"1", "2", "1"}[i]
+		if got := favicon(t, c, ctx); got != want {
+			t.Errorf("account %s: Favicon() = %s, want %s", site.UserId, got, want)
+		}
+	}
+	if hits.Load() != 2 {
+		t.Errorf("hits = %d, want 2", hits.Load())
+	}
+}
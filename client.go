@@ -1,14 +1,19 @@
 package btsite
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/heibizi/go-siteadapt"
+	"net/http"
 	"net/url"
-	"strings"
+	"reflect"
+	"sync"
+	"time"
 )
 
 type (
-	// Client 站点客户端
+	// Client 站点客户端，接口可能增加方法，自定义实现建议通过 AdaptClient 适配 ClientContext
 	Client interface {
 		// Favicon 获取站点 favicon 文件
 		Favicon() ([]byte, error)
@@ -35,9 +40,38 @@ type (
 		// Details 获取种子详情
 		Details(id string) (TorrentDetail, error)
 	}
+	// ClientContext 支持 context 的站点客户端，方法含义同 Client，ctx 取消或超时后请求立即返回。
+	// 接口可能增加方法，自定义实现建议嵌入 *NexusPHPClient，只重写需要的方法
+	ClientContext interface {
+		// Favicon 获取站点 favicon 文件
+		Favicon(ctx context.Context) ([]byte, error)
+		// UserBasicInfo 获取用户基础信息，通常是首页能拿到的
+		UserBasicInfo(ctx context.Context) (UserBasicInfo, error)
+		// UserDetails 获取用户详情，通常是详情页能拿到的
+		UserDetails(ctx context.Context) (UserDetails, error)
+		// Search 搜索种子列表
+		Search(ctx context.Context, searchParams SearchParams) ([]SearchTorrent, error)
+		// SeedingStatistics 获取做种统计信息
+		SeedingStatistics(ctx context.Context) (SeedingStatistics, error)
+		// MyHr HR 考核中列表
+		MyHr(ctx context.Context) ([]HrTorrent, error)
+		// UnreadMessages 未读消息列表，只取第一页，bool：是否跳转到详情
+		UnreadMessages(ctx context.Context, detail bool) ([]Message, error)
+		// LatestNotice 最新公告
+		LatestNotice(ctx context.Context) (*Notice, error)
+		// Rss RSS 拉取
+		Rss(ctx context.Context) ([]RssTorrent, error)
+		// SignIn 签到
+		SignIn(ctx context.Context) (SignInResult, error)
+		// GetDownloadUrl 获取种子下载地址
+		GetDownloadUrl(ctx context.Context, torrent SearchTorrent) (string, error)
+		// Details 获取种子详情
+		Details(ctx context.Context, id string) (TorrentDetail, error)
+	}
 	// requestSiteParams 站点请求参数
 	// 自定义请求的优先级：reqId > rd > schema
 	requestSiteParams struct {
+		ctx      context.Context              // 为空时使用 context.Background()
 		site     *Site                        // 站点
 		reqId    requestId                    // 请求 id
 		rd       *siteadapt.RequestDefinition // 自定义请求
@@ -46,33 +80,76 @@ type (
 		formData url.Values                   // form-data 请求参数
 		env      map[string]string            // 环境变量
 		body     map[string]any               // 请求体
+		headers  map[string]string            // 额外的请求头，优先于站点配置的请求头，仅 fetch 使用
+		maxSize  int64                        // 响应体的最大字节数，超过时返回异常，<= 0 时不限制，仅 fetch 使用
+		check    func(data []byte) error      // 校验原始数据，校验失败时返回异常且不缓存，读取缓存时也会校验，仅 raw 使用
 	}
 )
 
-// 注册 Client 实现类的构造函数映射
-var clientFactoryRegistry = map[string]func(*Site) Client{
-	string(siteSchemaNexusPHP): func(site *Site) Client { return &npClient{site} },
-	string(siteSchemaMTorrent): func(site *Site) Client {
-		return &mtClient{site, &npClient{site}}
+// ClientFactory 客户端构造函数
+type ClientFactory func(site *Site) ClientContext
+
+// 注册 ClientContext 实现类的构造函数映射
+var clientFactoryRegistry = struct {
+	sync.RWMutex
+	m map[string]ClientFactory
+}{m: map[string]ClientFactory{
+	string(siteSchemaNexusPHP): func(site *Site) ClientContext { return NewNexusPHPClient(site) },
+	string(siteSchemaMTorrent): func(site *Site) ClientContext {
+		return &mtClient{site, NewNexusPHPClient(site)}
+	},
+	string(siteSchemaGazelle): func(site *Site) ClientContext {
+		return &gzClient{site: site, NexusPHPClient: NewNexusPHPClient(site)}
+	},
+	string(siteSchemaUNIT3D): func(site *Site) ClientContext {
+		return &u3dClient{site, NewNexusPHPClient(site)}
 	},
+	string(siteSchemaDiscuz): func(site *Site) ClientContext {
+		return &dzClient{site, NewNexusPHPClient(site)}
+	},
+}}
+
+// RegisterSchema 注册系统架构的客户端构造函数，站点配置的 schema 为 name 时使用 factory 创建客户端。
+// 自定义客户端可以嵌入 *NexusPHPClient，只重写需要的方法。重复注册同一架构时返回异常。
+func RegisterSchema(name string, factory ClientFactory) error {
+	if name == "" || factory == nil {
+		return fmt.Errorf("%w: 架构名称和构造函数不能为空", ErrInvalidSchema)
+	}
+	clientFactoryRegistry.Lock()
+	defer clientFactoryRegistry.Unlock()
+	if _, exists := clientFactoryRegistry.m[name]; exists {
+		return fmt.Errorf("架构已注册: %s", name)
+	}
+	clientFactoryRegistry.m[name] = factory
+	return nil
+}
+
+// clientFactory 获取系统架构的客户端构造函数
+func clientFactory(schema string) (ClientFactory, bool) {
+	clientFactoryRegistry.RLock()
+	defer clientFactoryRegistry.RUnlock()
+	factory, ok := clientFactoryRegistry.m[schema]
+	return factory, ok
+}
+
+// AdaptClient 将 ClientContext 适配为 Client，Client 的方法使用 context.Background()
+func AdaptClient(c ClientContext) Client {
+	return &clientAdapter{c}
 }
 
 // NewClient 根据站点的唯一标识获取站点配置，根据站点配置的系统架构类型创建客户端
 func NewClient(site *Site) (Client, error) {
-	sc, err := SiteHelper.GetConfigByCode(site.Code)
-	if err != nil {
-		return nil, err
-	}
-	if constructor, ok := clientFactoryRegistry[sc.Schema]; ok {
-		return constructor(site), nil
-	}
-	return nil, fmt.Errorf("无效架构: %s", sc.Schema)
+	return defaultRegistry.NewClient(site)
+}
+
+// NewClientContext 同 NewClient，创建支持 context 的客户端
+func NewClientContext(site *Site) (ClientContext, error) {
+	return defaultRegistry.NewClientContext(site)
 }
 
 func newSiteAdapt(params requestSiteParams) (*siteadapt.SiteAdaptor, *siteadapt.RequestSiteParams, error) {
-	sh := SiteHelper
 	site := params.site
-	sc, err := sh.GetConfigByCode(site.Code)
+	sc, err := siteConfig(site)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -100,16 +177,7 @@ func newSiteAdapt(params requestSiteParams) (*siteadapt.SiteAdaptor, *siteadapt.
 		Cookie:   site.Cookie,
 	}
 	// 自定义请求头
-	if len(site.Headers) > 0 {
-		headers := make(map[string]string)
-		for _, header := range strings.Split(site.Headers, "\n") {
-			kvs := strings.Split(strings.TrimSpace(header), ":")
-			if len(kvs) == 2 {
-				headers[kvs[0]] = kvs[1]
-			}
-		}
-		rsp.Headers = headers
-	}
+	rsp.Headers = parseHeaders(site.Headers)
 	return siteadapt.NewSiteAdaptor(sc.Config), &rsp, nil
 }
 
@@ -119,10 +187,33 @@ func data(params requestSiteParams, output any, fn siteadapt.DataFunc) error {
 	if err != nil {
 		return err
 	}
-	err = sa.Data(*rsp, output, fn)
+	// 有回调时无法缓存回调的结果，不使用缓存
+	var rc *responseCache
+	if fn == nil {
+		rc = cacheFor(params)
+	}
+	if rc.load(output) {
+		return nil
+	}
+	var result siteadapt.DataResult
+	err = execute(params, func(ctx context.Context) error {
+		var err error
+		result, err = do(ctx, output, func(output any) (siteadapt.DataResult, error) {
+			var result siteadapt.DataResult
+			err := sa.Data(*rsp, output, func(r siteadapt.DataResult) {
+				result = r
+			})
+			return result, err
+		})
+		return dataError(params, result, err)
+	})
 	if err != nil {
 		return err
 	}
+	rc.store(output)
+	if fn != nil {
+		fn(result)
+	}
 	return nil
 }
 
@@ -132,10 +223,32 @@ func list(params requestSiteParams, output any, fn siteadapt.ListFunc) error {
 	if err != nil {
 		return err
 	}
-	err = sa.List(*rsp, output, fn)
+	var rc *responseCache
+	if fn == nil {
+		rc = cacheFor(params)
+	}
+	if rc.load(output) {
+		return nil
+	}
+	var result siteadapt.ListResult
+	err = execute(params, func(ctx context.Context) error {
+		var err error
+		result, err = do(ctx, output, func(output any) (siteadapt.ListResult, error) {
+			var result siteadapt.ListResult
+			err := sa.List(*rsp, output, func(r siteadapt.ListResult) {
+				result = r
+			})
+			return result, err
+		})
+		return newSiteError(params, 0, rsp.Path, err)
+	})
 	if err != nil {
 		return err
 	}
+	rc.store(output)
+	if fn != nil {
+		fn(result)
+	}
 	return nil
 }
 
@@ -145,13 +258,198 @@ func raw(params requestSiteParams, fn siteadapt.RawFunc) error {
 	if err != nil {
 		return err
 	}
-	err = sa.Raw(*rsp, fn)
+	rc := cacheFor(params)
+	if b, ok := rc.get(); ok && (params.check == nil || params.check(b) == nil) {
+		fn(siteadapt.RawResult{Data: b})
+		return nil
+	}
+	var data []byte
+	err = execute(params, func(ctx context.Context) error {
+		var err error
+		data, err = do(ctx, nil, func(any) ([]byte, error) {
+			var data []byte
+			err := sa.Raw(*rsp, func(result siteadapt.RawResult) {
+				data = result.Data
+			})
+			return data, err
+		})
+		return newSiteError(params, 0, rsp.Path, err)
+	})
 	if err != nil {
 		return err
 	}
+	if params.check != nil {
+		if err := params.check(data); err != nil {
+			return err
+		}
+	}
+	rc.set(data)
+	fn(siteadapt.RawResult{Data: data})
 	return nil
 }
 
-func newError(site *Site, err error, format string, v ...any) error {
-	return fmt.Errorf("站点(%s)%s, 异常: %v", site.Name, fmt.Sprintf(format, v...), err)
+// dataError 根据 siteadapt 返回的结果包装异常。siteadapt 没有异常时，
+// 请求地址为登录页视为未登录，状态码为 429、5xx 视为请求失败，避免将错误页面当作正常数据解析
+func dataError(params requestSiteParams, result siteadapt.DataResult, err error) error {
+	if err == nil {
+		switch {
+		case isLoginUrl(result.RequestUrl):
+			err = errors.New("重定向到登录页")
+		case result.StatusCode == http.StatusTooManyRequests || result.StatusCode >= http.StatusInternalServerError:
+			err = fmt.Errorf("状态码异常: %d", result.StatusCode)
+		}
+	}
+	return newSiteError(params, result.StatusCode, result.RequestUrl, err)
+}
+
+// do 执行 siteadapt 请求，ctx 取消或超时后立即返回 ctx.Err()。
+// siteadapt 的请求不支持 context，取消后请求会在后台执行完毕，其结果被丢弃：
+// fn 解析到 output 类型的新值中，请求完成且 ctx 未结束时才复制到 output 并返回 fn 的结果，
+// 后台的请求不会再修改 output，重试时也不会保留上一次请求解析的部分数据
+func do[T any](ctx context.Context, output any, fn func(output any) (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	type done struct {
+		result T
+		err    error
+	}
+	out := newOutput(output)
+	ch := make(chan done, 1)
+	go func() {
+		result, err := fn(out)
+		ch <- done{result, err}
+	}()
+	select {
+	case d := <-ch:
+		if d.err == nil {
+			setOutput(output, out)
+		}
+		return d.result, d.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// newOutput 创建与 output 指向的类型相同的零值，output 不是非空指针时原样返回
+func newOutput(output any) any {
+	v := reflect.ValueOf(output)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return output
+	}
+	return reflect.New(v.Elem().Type()).Interface()
+}
+
+// setOutput 将 newOutput 创建的值复制到 output
+func setOutput(output, out any) {
+	v := reflect.ValueOf(output)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return
+	}
+	v.Elem().Set(reflect.ValueOf(out).Elem())
+}
+
+// execute 按站点配置限流、重试执行请求，fn 的 ctx 为单次请求的 ctx，已包含站点的超时时间
+func execute(params requestSiteParams, fn func(ctx context.Context) error) error {
+	ctx := params.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sc, err := siteConfig(params.site)
+	if err != nil {
+		return err
+	}
+	limiter := limiterFor(params.site.Code, sc.RateLimit)
+	policy := sc.Retry.policyFor(params.reqId)
+	for attempt := 1; ; attempt++ {
+		if err := limiter.wait(ctx); err != nil {
+			return err
+		}
+		err = attemptTimeout(ctx, params.site.Timeout, fn)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(params.reqId, err) {
+			return err
+		}
+		if err := sleep(ctx, policy.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// attemptTimeout 执行单次请求，timeout > 0 时限制单次请求的时间
+func attemptTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// sleep 等待 d 或 ctx 结束，ctx 结束时返回 ctx.Err()
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// clientAdapter 使用 context.Background() 将 ClientContext 适配为 Client
+type clientAdapter struct {
+	c ClientContext
+}
+
+func (a *clientAdapter) Favicon() ([]byte, error) {
+	return a.c.Favicon(context.Background())
+}
+
+func (a *clientAdapter) UserBasicInfo() (UserBasicInfo, error) {
+	return a.c.UserBasicInfo(context.Background())
+}
+
+func (a *clientAdapter) UserDetails() (UserDetails, error) {
+	return a.c.UserDetails(context.Background())
+}
+
+func (a *clientAdapter) Search(searchParams SearchParams) ([]SearchTorrent, error) {
+	return a.c.Search(context.Background(), searchParams)
+}
+
+func (a *clientAdapter) SeedingStatistics() (SeedingStatistics, error) {
+	return a.c.SeedingStatistics(context.Background())
+}
+
+func (a *clientAdapter) MyHr() ([]HrTorrent, error) {
+	return a.c.MyHr(context.Background())
+}
+
+func (a *clientAdapter) UnreadMessages(detail bool) ([]Message, error) {
+	return a.c.UnreadMessages(context.Background(), detail)
+}
+
+func (a *clientAdapter) LatestNotice() (*Notice, error) {
+	return a.c.LatestNotice(context.Background())
+}
+
+func (a *clientAdapter) Rss() ([]RssTorrent, error) {
+	return a.c.Rss(context.Background())
+}
+
+func (a *clientAdapter) SignIn() (SignInResult, error) {
+	return a.c.SignIn(context.Background())
+}
+
+func (a *clientAdapter) GetDownloadUrl(torrent SearchTorrent) (string, error) {
+	return a.c.GetDownloadUrl(context.Background(), torrent)
+}
+
+func (a *clientAdapter) Details(id string) (TorrentDetail, error) {
+	return a.c.Details(context.Background(), id)
 }
@@ -0,0 +1,43 @@
+package btsite_test
+
+import (
+	"context"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-siteadapt"
+	"testing"
+)
+
+type customClient struct {
+	*btsite.NexusPHPClient
+}
+
+func (c *customClient) Search(ctx context.Context, searchParams btsite.SearchParams) ([]btsite.SearchTorrent, error) {
+	return []btsite.SearchTorrent{{ID: c.Site().Code, Title: searchParams.Keyword}}, nil
+}
+
+func TestRegisterSchema(t *testing.T) {
+	factory := func(site *btsite.Site) btsite.ClientContext {
+		return &customClient{btsite.NewNexusPHPClient(site)}
+	}
+	if err := btsite.RegisterSchema("go-btsite-custom", factory); err != nil {
+		t.Fatal(err)
+	}
+	if err := btsite.RegisterSchema("go-btsite-custom", factory); err == nil {
+		t.Error("RegisterSchema() duplicate err = nil, want error")
+	}
+	if err := btsite.RegisterSchema("NexusPHP", factory); err == nil {
+		t.Error("RegisterSchema(NexusPHP) err = nil, want error")
+	}
+	r := btsite.NewRegistry(&btsite.AdaptCfg{Configs: []btsite.Config{{
+		Config: siteadapt.Config{ID: "custom"},
+		Schema: "go-btsite-custom",
+	}}})
+	c, err := r.NewClient(&btsite.Site{Code: "custom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	torrents, err := c.Search(btsite.SearchParams{Keyword: "keyword"})
+	if err != nil || len(torrents) != 1 || torrents[0].ID != "custom" || torrents[0].Title != "keyword" {
+		t.Errorf("Search() = %+v, %v", torrents, err)
+	}
+}
@@ -10,88 +10,119 @@ import (
 
 var client btsite.Client
 
+// TestMain 未配置 GO_BTSITE_CONFIGS_PATH 时不加载站点配置，未配置 GO_BTSITE_CODE 时跳过请求真实站点的测试
 func TestMain(m *testing.M) {
-	btsite.InitConfig(os.Getenv("GO_BTSITE_CONFIGS_PATH"))
-	client, _ = btsite.NewClient(&btsite.Site{
+	if path := os.Getenv("GO_BTSITE_CONFIGS_PATH"); path != "" {
+		btsite.InitConfig(path)
+	}
+	if os.Getenv("GO_BTSITE_CODE") != "" {
+		client, _ = btsite.NewClient(liveSite())
+	}
+	m.Run()
+}
+
+// liveSite 使用 GO_BTSITE_* 环境变量配置的站点
+func liveSite() *btsite.Site {
+	return &btsite.Site{
 		Code:      os.Getenv("GO_BTSITE_CODE"),
 		Name:      os.Getenv("GO_BTSITE_NAME"),
+		UserId:    os.Getenv("GO_BTSITE_USER_ID"),
 		UserAgent: os.Getenv("GO_BTSITE_UA"),
 		Cookie:    os.Getenv("GO_BTSITE_COOKIE"),
 		RssUrl:    os.Getenv("GO_BTSITE_RSS_URL"),
-	})
-	m.Run()
+		ApiToken:  os.Getenv("GO_BTSITE_API_TOKEN"),
+	}
+}
+
+// liveClient 请求真实站点的客户端，未配置时跳过测试
+func liveClient(t *testing.T) btsite.Client {
+	if client == nil {
+		t.Skip("未配置 GO_BTSITE_CONFIGS_PATH、GO_BTSITE_CODE")
+	}
+	return client
 }
 
 func TestUserBasicInfo(t *testing.T) {
-	info, err := client.UserBasicInfo()
+	c := liveClient(t)
+	info, err := c.UserBasicInfo()
 	log(info, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestUserDetails(t *testing.T) {
-	details, err := client.UserDetails()
+	c := liveClient(t)
+	details, err := c.UserDetails()
 	log(details, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestSeedingStatistics(t *testing.T) {
-	statistics, err := client.SeedingStatistics()
+	c := liveClient(t)
+	statistics, err := c.SeedingStatistics()
 	log(statistics, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestFavicon(t *testing.T) {
-	favicon, err := client.Favicon()
+	c := liveClient(t)
+	favicon, err := c.Favicon()
 	log(favicon, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestMyHr(t *testing.T) {
-	hr, err := client.MyHr()
+	c := liveClient(t)
+	hr, err := c.MyHr()
 	log(hr, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestUnreadMessage(t *testing.T) {
-	messages, err := client.UnreadMessages(true)
+	c := liveClient(t)
+	messages, err := c.UnreadMessages(true)
 	log(messages, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestLatestNotice(t *testing.T) {
-	notice, err := client.LatestNotice()
+	c := liveClient(t)
+	notice, err := c.LatestNotice()
 	log(notice, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestSignIn(t *testing.T) {
-	r, err := client.SignIn()
+	c := liveClient(t)
+	r, err := c.SignIn()
 	log(r, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestDetails(t *testing.T) {
-	details, err := client.Details(os.Getenv("GO_BTSITE_TORRENT_ID"))
+	c := liveClient(t)
+	details, err := c.Details(os.Getenv("GO_BTSITE_TORRENT_ID"))
 	log(details, err, t)
 	time.Sleep(1 * time.Second)
 }
 
 func TestSearch(t *testing.T) {
-	torrents, err := client.Search(btsite.SearchParams{
+	c := liveClient(t)
+	torrents, err := c.Search(btsite.SearchParams{
 		Keyword:   "",
 		MediaType: btsite.Movie,
 		Page:      0,
 	})
 	log(torrents, err, t)
 	if len(torrents) > 0 {
-		url, err := client.GetDownloadUrl(torrents[0])
+		url, err := c.GetDownloadUrl(torrents[0])
 		log(url, err, t)
 	}
 	time.Sleep(1 * time.Second)
 }
 
 func TestRss(t *testing.T) {
-	rss, err := client.Rss()
+	c := liveClient(t)
+	rss, err := c.Rss()
 	log(rss, err, t)
 	time.Sleep(1 * time.Second)
 }
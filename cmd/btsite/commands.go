@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/heibizi/go-btsite"
+	"io"
+	"strings"
+)
+
+// errUsage 命令参数错误
+var errUsage = errors.New("参数错误")
+
+type userInfoResult struct {
+	btsite.UserBasicInfo
+	btsite.UserDetails
+}
+
+// noArgs 不需要参数的命令
+func noArgs(fn func(ctx context.Context, c btsite.ClientContext) (any, error)) func(context.Context, btsite.ClientContext, []string) (any, error) {
+	return func(ctx context.Context, c btsite.ClientContext, args []string) (any, error) {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("%w: 多余的参数 %s", errUsage, strings.Join(args, " "))
+		}
+		return fn(ctx, c)
+	}
+}
+
+// oneArg 需要一个参数的命令
+func oneArg(name string, fn func(ctx context.Context, c btsite.ClientContext, arg string) (any, error)) func(context.Context, btsite.ClientContext, []string) (any, error) {
+	return func(ctx context.Context, c btsite.ClientContext, args []string) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: 需要参数 %s", errUsage, name)
+		}
+		return fn(ctx, c, args[0])
+	}
+}
+
+func userInfo(ctx context.Context, c btsite.ClientContext, args []string) (any, error) {
+	return noArgs(func(ctx context.Context, c btsite.ClientContext) (any, error) {
+		basic, err := c.UserBasicInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details, err := c.UserDetails(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return userInfoResult{basic, details}, nil
+	})(ctx, c, args)
+}
+
+func search(ctx context.Context, c btsite.ClientContext, args []string) (any, error) {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	mediaType := fs.String("type", "", "媒体类型：movie、tv、anime")
+	page := fs.Int("page", 0, "页码，从 0 开始")
+	all := fs.Int("all", 0, "从 -page 开始自动翻页，最多返回的种子数量，0 为只搜索一页")
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUsage, err)
+	}
+	searchParams := btsite.SearchParams{
+		Keyword: strings.Join(fs.Args(), " "),
+		Page:    *page,
+	}
+	if *mediaType != "" {
+		found := false
+		for _, mt := range btsite.MediaTypes {
+			if mt.Code == *mediaType {
+				searchParams.MediaType = mt
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: 不支持的媒体类型 %s", errUsage, *mediaType)
+		}
+	}
+	if *all <= 0 {
+		return c.Search(ctx, searchParams)
+	}
+	var torrents []btsite.SearchTorrent
+	cursor := btsite.SearchAll(ctx, c, searchParams, *all)
+	for cursor.Next() {
+		torrents = append(torrents, cursor.Torrent())
+	}
+	return torrents, cursor.Err()
+}
+
+func messages(ctx context.Context, c btsite.ClientContext, args []string) (any, error) {
+	fs := flag.NewFlagSet("messages", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	detail := fs.Bool("detail", false, "获取消息详情，部分站点会将消息设为已读")
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUsage, err)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("%w: 多余的参数 %s", errUsage, strings.Join(fs.Args(), " "))
+	}
+	return c.UnreadMessages(ctx, *detail)
+}
+
+// downloadUrl 根据种子 id 获取下载地址，站点只能从搜索结果获取下载地址时返回异常
+func downloadUrl(ctx context.Context, c btsite.ClientContext, id string) (any, error) {
+	u, err := c.GetDownloadUrl(ctx, btsite.SearchTorrent{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	if u == "" {
+		return nil, errors.New("该站点不支持根据 id 获取下载地址，请使用搜索结果中的下载地址")
+	}
+	return u, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/heibizi/go-btsite"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	// credential 站点凭证，对应 btsite.Site 的字段
+	credential struct {
+		UserId             string `json:"user_id"`
+		Cookie             string `json:"cookie"`
+		UserAgent          string `json:"user_agent"`
+		Headers            string `json:"headers"`
+		RssUrl             string `json:"rss_url"`
+		ApiToken           string `json:"api_token"`
+		AnnounceUrl        string `json:"announce_url"`
+		Domain             string `json:"domain"`
+		Api                string `json:"api"`
+		Proxy              string `json:"proxy"`
+		Timeout            string `json:"timeout"`
+		InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	}
+	// credentials 凭证文件，key 为站点唯一标识
+	credentials map[string]credential
+)
+
+// defaultCredentialsPath 默认凭证文件路径，优先使用环境变量 BTSITE_CREDENTIALS
+func defaultCredentialsPath() string {
+	if path := os.Getenv("BTSITE_CREDENTIALS"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "credentials.json"
+	}
+	return filepath.Join(dir, "btsite", "credentials.json")
+}
+
+// loadCredentials 读取凭证文件
+func loadCredentials(path string) (credentials, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取凭证文件失败: %w", err)
+	}
+	var creds credentials
+	if err := json.Unmarshal(b, &creds); err != nil {
+		return nil, fmt.Errorf("解析凭证文件失败: %s: %w", path, err)
+	}
+	return creds, nil
+}
+
+// site 根据站点配置和凭证创建站点，凭证中未配置的站点只使用站点配置
+func (creds credentials) site(sc btsite.Config) (*btsite.Site, error) {
+	cred := creds[sc.ID]
+	site := &btsite.Site{
+		Code:               sc.ID,
+		Name:               sc.Name,
+		UserId:             cred.UserId,
+		Api:                cred.Api,
+		Domain:             cred.Domain,
+		UserAgent:          cred.UserAgent,
+		Cookie:             cred.Cookie,
+		Headers:            cred.Headers,
+		RssUrl:             cred.RssUrl,
+		ApiToken:           cred.ApiToken,
+		AnnounceUrl:        cred.AnnounceUrl,
+		Proxy:              cred.Proxy,
+		InsecureSkipVerify: cred.InsecureSkipVerify,
+	}
+	if cred.Timeout != "" {
+		timeout, err := time.ParseDuration(cred.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("站点 %s 超时时间格式错误: %w", sc.ID, err)
+		}
+		site.Timeout = timeout
+	}
+	return site, nil
+}
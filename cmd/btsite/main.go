@@ -0,0 +1,239 @@
+// btsite 站点适配调试工具，使用本地凭证文件请求站点，以 JSON 或表格输出结果。
+//
+// 用法：
+//
+//	btsite [-configs 目录] [-credentials 文件] [-o json|table] [-timeout 时长] <命令> [参数]
+//
+// 凭证文件为 JSON，key 为站点唯一标识：
+//
+//	{"hdsky": {"cookie": "...", "user_agent": "...", "user_id": "..."}}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/heibizi/go-btsite"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+type (
+	// command 子命令，run 的 args 不包含站点唯一标识
+	command struct {
+		name  string
+		usage string
+		desc  string
+		run   func(ctx context.Context, c btsite.ClientContext, args []string) (any, error)
+	}
+	// siteRow sites 命令输出的站点信息
+	siteRow struct {
+		ID     string
+		Name   string
+		Schema string
+		Domain string
+		Public bool
+	}
+)
+
+var commands = []command{
+	{"userinfo", "<site>", "用户基础信息和详情", userInfo},
+	{"search", "<site> [-type movie|tv|anime] [-page n] [-all n] [关键字]", "搜索种子", search},
+	{"seeding", "<site>", "做种统计", noArgs(func(ctx context.Context, c btsite.ClientContext) (any, error) {
+		return c.SeedingStatistics(ctx)
+	})},
+	{"hr", "<site>", "HR 考核中列表", noArgs(func(ctx context.Context, c btsite.ClientContext) (any, error) {
+		return c.MyHr(ctx)
+	})},
+	{"messages", "<site> [-detail]", "未读消息", messages},
+	{"notice", "<site>", "最新公告", noArgs(func(ctx context.Context, c btsite.ClientContext) (any, error) {
+		return c.LatestNotice(ctx)
+	})},
+	{"rss", "<site>", "RSS 种子列表，需要在凭证中配置 rss_url", noArgs(func(ctx context.Context, c btsite.ClientContext) (any, error) {
+		return c.Rss(ctx)
+	})},
+	{"signin", "<site>", "签到", noArgs(func(ctx context.Context, c btsite.ClientContext) (any, error) {
+		return c.SignIn(ctx)
+	})},
+	{"details", "<site> <id>", "种子详情", oneArg("id", func(ctx context.Context, c btsite.ClientContext, id string) (any, error) {
+		return c.Details(ctx, id)
+	})},
+	{"download-url", "<site> <id>", "种子下载地址", oneArg("id", downloadUrl)},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run 执行命令，返回进程退出码
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("btsite", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configs := fs.String("configs", defaultConfigsPath(), "站点配置目录，包含 commons 和 sites，默认读取环境变量 GO_BTSITE_CONFIGS_PATH")
+	credentialsPath := fs.String("credentials", defaultCredentialsPath(), "凭证文件，默认读取环境变量 BTSITE_CREDENTIALS")
+	format := fs.String("o", formatTable, "输出格式：json、table")
+	timeout := fs.Duration("timeout", time.Minute, "命令超时时间，0 为不限制")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	name, rest := fs.Arg(0), fs.Args()[1:]
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if name == "lint" {
+		return lint(*configs, *format, stdout, stderr)
+	}
+	registry, err := loadRegistry(*configs, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var result any
+	if name == "sites" {
+		result = sites(registry)
+	} else {
+		cmd, ok := findCommand(name)
+		if !ok {
+			fmt.Fprintf(stderr, "未知命令: %s\n", name)
+			fs.Usage()
+			return 2
+		}
+		if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+			fmt.Fprintf(stderr, "用法: btsite %s %s\n", cmd.name, cmd.usage)
+			return 2
+		}
+		c, err := newClient(registry, *credentialsPath, rest[0])
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if result, err = cmd.run(ctx, c, rest[1:]); err != nil {
+			fmt.Fprintln(stderr, err)
+			if errors.Is(err, errUsage) {
+				fmt.Fprintf(stderr, "用法: btsite %s %s\n", cmd.name, cmd.usage)
+				return 2
+			}
+			return 1
+		}
+	}
+	if err := printResult(stdout, *format, result); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "用法: btsite [选项] <命令> [参数]")
+	fmt.Fprintln(w, "\n命令:")
+	fmt.Fprintf(w, "  %-14s %s\n", "sites", "所有支持的站点")
+	fmt.Fprintf(w, "  %-14s %s\n", "lint", "检查站点配置，存在错误时退出码为 1")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.desc)
+	}
+	fmt.Fprintln(w, "\n选项:")
+	fs.PrintDefaults()
+}
+
+func defaultConfigsPath() string {
+	if path := os.Getenv("GO_BTSITE_CONFIGS_PATH"); path != "" {
+		return path
+	}
+	return "configs"
+}
+
+// loadRegistry 加载站点配置，单个文件异常时输出警告并继续
+func loadRegistry(path string, stderr io.Writer) (*btsite.Registry, error) {
+	conf, err := btsite.LoadConfig(os.DirFS(path))
+	if conf == nil {
+		return nil, fmt.Errorf("加载站点配置失败: %s: %w", path, err)
+	}
+	var errs btsite.ConfigErrors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			fmt.Fprintln(stderr, "警告:", e.Error())
+		}
+	}
+	return btsite.NewRegistry(conf), nil
+}
+
+// lint 检查站点配置，配置文件的加载异常也作为错误输出，存在错误时返回 1
+func lint(path string, format string, stdout io.Writer, stderr io.Writer) int {
+	conf, err := btsite.LoadConfig(os.DirFS(path))
+	if conf == nil {
+		fmt.Fprintf(stderr, "加载站点配置失败: %s: %v\n", path, err)
+		return 1
+	}
+	var issues []btsite.Issue
+	var errs btsite.ConfigErrors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			issues = append(issues, btsite.Issue{Level: btsite.IssueError, Config: e.File, Message: e.Err.Error()})
+		}
+	}
+	issues = append(issues, btsite.Validate(*conf)...)
+	if err := printResult(stdout, format, issues); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for _, issue := range issues {
+		if issue.Level == btsite.IssueError {
+			return 1
+		}
+	}
+	return 0
+}
+
+func newClient(registry *btsite.Registry, credentialsPath string, code string) (btsite.ClientContext, error) {
+	sc, err := registry.GetConfigByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := loadCredentials(credentialsPath)
+	if err != nil {
+		return nil, err
+	}
+	site, err := creds.site(sc)
+	if err != nil {
+		return nil, err
+	}
+	return registry.NewClientContext(site)
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func sites(registry *btsite.Registry) []siteRow {
+	var rows []siteRow
+	for _, sc := range registry.AllSupportedSites() {
+		rows = append(rows, siteRow{
+			ID:     sc.ID,
+			Name:   sc.Name,
+			Schema: sc.Schema,
+			Domain: sc.Domain,
+			Public: sc.Public,
+		})
+	}
+	return rows
+}
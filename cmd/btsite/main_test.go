@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setup 使用 Gazelle 的公共配置和 testdata 创建站点配置目录和凭证文件
+func setup(t *testing.T) (configs string, credentials string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "session=cli" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("..", "..", "testdata", "gazelle", r.URL.Query().Get("action")+".json"))
+	}))
+	t.Cleanup(srv.Close)
+	dir := t.TempDir()
+	common, err := os.ReadFile(filepath.Join("..", "..", "configs", "commons", "Gazelle.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"configs/commons/Gazelle.json": string(common),
+		"configs/sites/fixture.json":   `{"id": "fixture", "name": "Fixture", "schema": "Gazelle", "domain": "` + srv.URL + `/"}`,
+		"credentials.json":             `{"fixture": {"cookie": "session=cli", "timeout": "10s"}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "configs"), filepath.Join(dir, "credentials.json")
+}
+
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	configs, credentials := setup(t)
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-configs", configs, "-credentials", credentials}, args...)
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestSites(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "sites")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "Gazelle") {
+		t.Errorf("got %q", stdout)
+	}
+}
+
+func TestSearchJSON(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "-o", "json", "search", "fixture", "-type", "movie", "The", "Matrix")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	var torrents []struct {
+		ID    string
+		Title string
+	}
+	if err := json.Unmarshal([]byte(stdout), &torrents); err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) == 0 || torrents[0].ID == "" || torrents[0].Title == "" {
+		t.Errorf("got %s", stdout)
+	}
+}
+
+func TestSearchAll(t *testing.T) {
+	// 测试数据不区分页码，第二页全部重复后停止
+	code, stdout, stderr := runCLI(t, "-o", "json", "search", "fixture", "-all", "1000", "Matrix")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	var torrents []struct{ ID string }
+	if err := json.Unmarshal([]byte(stdout), &torrents); err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 3 {
+		t.Errorf("got %d torrents, want 3: %s", len(torrents), stdout)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"details", "fixture"},
+		{"search", "fixture", "-type", "music"},
+		{"seeding"},
+	} {
+		if code, _, _ := runCLI(t, args...); code != 2 {
+			t.Errorf("%v: exit %d, want 2", args, code)
+		}
+	}
+	if code, _, stderr := runCLI(t, "signin", "missing"); code != 1 || !strings.Contains(stderr, "missing") {
+		t.Errorf("unknown site: exit %d: %s", code, stderr)
+	}
+}
+
+func TestLint(t *testing.T) {
+	if code, stdout, stderr := runCLI(t, "lint"); code != 0 {
+		t.Fatalf("exit %d: %s%s", code, stdout, stderr)
+	}
+	configs, _ := setup(t)
+	broken := `{"id": "broken", "name": "Broken", "schema": "Gazelle", "reuse_schema": "Missing"}`
+	if err := os.WriteFile(filepath.Join(configs, "sites", "broken.json"), []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-configs", configs, "-o", "json", "lint"}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stdout.String(), "Missing") {
+		t.Errorf("exit %d: %s%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestDownloadUrl(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "-o", "json", "download-url", "fixture", "100")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	var u string
+	if err := json.Unmarshal([]byte(stdout), &u); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(u, "/torrents.php?action=download&id=100&authkey=auth123&torrent_pass=pass456") {
+		t.Errorf("got %s", u)
+	}
+}
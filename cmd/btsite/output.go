@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+)
+
+// printResult 按格式输出结果
+func printResult(w io.Writer, format string, v any) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(v)
+	case formatTable:
+		return printTable(w, v)
+	}
+	return fmt.Errorf("不支持的输出格式: %s", format)
+}
+
+// printTable 以表格输出，切片每个元素一行，结构体每个字段一行
+func printTable(w io.Writer, v any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch {
+	case !rv.IsValid():
+		fmt.Fprintln(tw, "(空)")
+	case rv.Kind() == reflect.Slice:
+		if rv.Len() == 0 {
+			fmt.Fprintln(tw, "(空)")
+			break
+		}
+		elem := rv.Type().Elem()
+		if elem.Kind() != reflect.Struct {
+			for i := 0; i < rv.Len(); i++ {
+				fmt.Fprintln(tw, cell(rv.Index(i)))
+			}
+			break
+		}
+		fields := visibleFields(elem)
+		var header []string
+		for _, f := range fields {
+			header = append(header, f.Name)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for i := 0; i < rv.Len(); i++ {
+			var row []string
+			for _, f := range fields {
+				row = append(row, cell(rv.Index(i).FieldByIndex(f.Index)))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	case rv.Kind() == reflect.Struct:
+		for _, f := range visibleFields(rv.Type()) {
+			fmt.Fprintf(tw, "%s\t%s\n", f.Name, cell(rv.FieldByIndex(f.Index)))
+		}
+	default:
+		fmt.Fprintln(tw, cell(rv))
+	}
+	return tw.Flush()
+}
+
+// visibleFields 导出的非嵌入字段，嵌入结构体的字段会展开
+func visibleFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for _, f := range reflect.VisibleFields(t) {
+		if f.IsExported() && !f.Anonymous {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// cell 单元格文本，换行和制表符替换为空格，避免破坏表格
+func cell(v reflect.Value) string {
+	var s string
+	switch v.Kind() {
+	case reflect.Slice:
+		var items []string
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, fmt.Sprint(v.Index(i).Interface()))
+		}
+		s = strings.Join(items, ",")
+	default:
+		s = fmt.Sprint(v.Interface())
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/heibizi/go-siteadapt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
-var globalConfig AdaptCfg
-
 type (
 	// AdaptCfg 站点适配配置
 	AdaptCfg struct {
@@ -29,6 +30,8 @@ type (
 		Schema       string `mapstructure:"schema"`        // 系统架构，对应 siteSchema
 		ReuseSchema  string `mapstructure:"reuse_schema"`  // 当前系统架构没有公共配置时，使用该系统架构的公共配置
 		CountMessage bool   `mapstructure:"count_message"` // 未读消息从未读消息列表统计数量
+		Announce     string `mapstructure:"announce"`      // tracker 地址，用于生成磁力链接，公开站点可以配置
+		Timezone     string `mapstructure:"timezone"`      // 站点时区，如 Asia/Shanghai、+08:00，用于解析不带时区的时间，默认为本地时区
 		// 必需参数
 		Required struct {
 			UserID bool `mapstructure:"user_id"`
@@ -47,10 +50,48 @@ type (
 			Has2XFree bool `mapstructure:"has_2x_free"` // 是否有 2XFree
 			HasHR     bool `mapstructure:"has_hr"`      // 是否有 HR
 		} `mapstructure:"price"`
+		RateLimit RateLimit      `mapstructure:"rate_limit"` // 限流配置，同一站点的所有客户端共享
+		Retry     RetryConfig    `mapstructure:"retry"`      // 重试配置
+		Cache     map[string]int `mapstructure:"cache"`      // 按请求 id 配置缓存秒数
+	}
+	// ConfigError 单个配置文件的加载异常
+	ConfigError struct {
+		File string // 文件路径
+		Err  error  // 异常原因
 	}
+	// ConfigErrors 配置文件的加载异常集合
+	ConfigErrors []ConfigError
 )
 
-// InitConfig 初始化站点适配文件
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+func (e ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func (errs ConfigErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("加载站点配置异常 %d 个文件:\n%s", len(errs), strings.Join(msgs, "\n"))
+}
+
+func (errs ConfigErrors) Unwrap() []error {
+	unwrapped := make([]error, 0, len(errs))
+	for _, err := range errs {
+		unwrapped = append(unwrapped, err)
+	}
+	return unwrapped
+}
+
+func (errs *ConfigErrors) add(file string, err error) {
+	*errs = append(*errs, ConfigError{File: file, Err: err})
+}
+
+// InitConfig 初始化站点适配文件，任一配置文件异常都会 panic，需要容错时请使用 LoadConfig
 func InitConfig(path string) {
 	if len(path) == 0 {
 		panic("站点配置文件路径未配置")
@@ -59,15 +100,32 @@ func InitConfig(path string) {
 	if err != nil && os.IsNotExist(err) {
 		panic(fmt.Errorf("站点配置文件路径错误: %s", path))
 	}
-	conf := AdaptCfg{}
-	common, err := loadCommonConfigs(path)
+	conf, err := LoadConfig(os.DirFS(path))
 	if err != nil {
 		panic(err)
 	}
+	SetConfig(conf)
+}
+
+// SetConfig 设置默认注册表使用的站点适配配置
+func SetConfig(conf *AdaptCfg) {
+	defaultRegistry.SetConfig(conf)
+}
+
+// LoadConfig 从 fsys 的 commons、sites 目录加载站点适配配置。
+// 单个文件异常时跳过该文件继续加载，返回已加载的配置以及 ConfigErrors；
+// 目录不存在等无法继续加载的异常返回 nil 配置。
+func LoadConfig(fsys fs.FS) (*AdaptCfg, error) {
+	var errs ConfigErrors
+	conf := AdaptCfg{}
+	common, err := loadCommonConfigs(fsys, &errs)
+	if err != nil {
+		return nil, err
+	}
 	conf.Common = common
-	configs, err := loadSiteConfigs(path)
+	configs, err := loadSiteConfigs(fsys, &errs)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	conf.Configs = configs
 	for i := range conf.Configs {
@@ -79,6 +137,9 @@ func InitConfig(path string) {
 			schemaSc, exists = conf.Common[sc.ReuseSchema]
 		}
 		if exists {
+			if sc.RequestDefinitions == nil {
+				sc.RequestDefinitions = make(map[string]siteadapt.RequestDefinition)
+			}
 			for schemaRdName, schemaRd := range schemaSc.RequestDefinitions {
 				rd, exists := sc.RequestDefinitions[schemaRdName]
 				if exists {
@@ -90,7 +151,10 @@ func InitConfig(path string) {
 			}
 		}
 	}
-	globalConfig = conf
+	if len(errs) > 0 {
+		return &conf, errs
+	}
+	return &conf, nil
 }
 
 func extend(rd *siteadapt.RequestDefinition, schemaRd *siteadapt.RequestDefinition) {
@@ -137,19 +201,19 @@ func extend(rd *siteadapt.RequestDefinition, schemaRd *siteadapt.RequestDefiniti
 	}
 }
 
-// listFiles 函数递归获取指定目录下的所有文件内容，并返回一个二维字节切片
-func listFiles(dir string) ([][]byte, error) {
-	var filesContent [][]byte
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+// listFiles 函数递归获取指定目录下的所有 json 文件内容，key 为文件路径
+func listFiles(fsys fs.FS, dir string) (map[string][]byte, error) {
+	filesContent := make(map[string][]byte)
+	err := fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && filepath.Ext(path) == ".json" {
-			content, err := os.ReadFile(path)
+		if !d.IsDir() && filepath.Ext(path) == ".json" {
+			content, err := fs.ReadFile(fsys, path)
 			if err != nil {
 				return err
 			}
-			filesContent = append(filesContent, content)
+			filesContent[path] = content
 		}
 		return nil
 	})
@@ -159,6 +223,16 @@ func listFiles(dir string) ([][]byte, error) {
 	return filesContent, nil
 }
 
+// sortedPaths 按文件路径排序，保证加载顺序稳定
+func sortedPaths(files map[string][]byte) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
 func readConfig[T any](file []byte, output *T) error {
 	var data interface{}
 	err := json.Unmarshal(file, &data)
@@ -171,60 +245,68 @@ func readConfig[T any](file []byte, output *T) error {
 	return nil
 }
 
-// loadCommonConfigs 加载公共配置
-func loadCommonConfigs(path string) (map[string]Config, error) {
+// loadCommonConfigs 加载公共配置，单个文件的异常记录到 errs
+func loadCommonConfigs(fsys fs.FS, errs *ConfigErrors) (map[string]Config, error) {
 	commons := make(map[string]Config)
-	path = filepath.Join(path, "commons")
-	files, err := listFiles(path)
+	files, err := listFiles(fsys, "commons")
 	if err != nil {
 		return nil, fmt.Errorf("加载公共配置异常: %v", err)
 	}
-	for _, file := range files {
+	for _, path := range sortedPaths(files) {
 		var common Config
-		configReader := siteadapt.NewConfigReader(file)
+		configReader := siteadapt.NewConfigReader(files[path])
 		config, err := configReader.Read()
 		if err != nil {
-			return nil, fmt.Errorf("加载公共配置异常: %v", err)
+			errs.add(path, fmt.Errorf("加载公共配置异常: %v", err))
+			continue
 		}
 		common.Config = *config
-		checkSearchFields(common.RequestDefinitions)
+		if err := checkSearchFields(common.RequestDefinitions); err != nil {
+			errs.add(path, err)
+			continue
+		}
 		commons[common.ID] = common
 	}
 	return commons, nil
 }
 
-// loadSiteConfigs 加载站点配置
-func loadSiteConfigs(path string) ([]Config, error) {
+// loadSiteConfigs 加载站点配置，单个文件的异常记录到 errs
+func loadSiteConfigs(fsys fs.FS, errs *ConfigErrors) ([]Config, error) {
 	var siteConfigs []Config
-	path = filepath.Join(path, "sites")
-	files, err := listFiles(path)
+	files, err := listFiles(fsys, "sites")
 	if err != nil {
 		return nil, fmt.Errorf("加载站点配置异常: %v", err)
 	}
-	for _, file := range files {
+	for _, path := range sortedPaths(files) {
+		file := files[path]
 		var sc Config
 		err := readConfig(file, &sc)
 		if err != nil {
-			return nil, err
+			errs.add(path, err)
+			continue
 		}
 		configReader := siteadapt.NewConfigReader(file)
 		config, err := configReader.Read()
 		if err != nil {
-			return nil, fmt.Errorf("加载站点配置异常: %v", err)
+			errs.add(path, fmt.Errorf("加载站点配置异常: %v", err))
+			continue
 		}
 		sc.Config = *config
-		checkSearchFields(sc.RequestDefinitions)
+		if err := checkSearchFields(sc.RequestDefinitions); err != nil {
+			errs.add(path, err)
+			continue
+		}
 		siteConfigs = append(siteConfigs, sc)
 	}
-	return siteConfigs, err
+	return siteConfigs, nil
 }
 
 var validFields = []string{"id", "category", "title", "details", "download", "size", "grabs", "seeders",
 	"leechers", "date_elapsed", "date_added", "downloadvolumefactor", "uploadvolumefactor", "description",
-	"labels", "hr_days", "imdbid"}
+	"labels", "hr_days", "imdbid", "infohash", "doubanid", "poster", "subtitle", "free_deadline"}
 
 // checkSearchFields 检查搜索字段，为了 json 的简洁性强制性要求不能乱配置
-func checkSearchFields(rds map[string]siteadapt.RequestDefinition) {
+func checkSearchFields(rds map[string]siteadapt.RequestDefinition) error {
 	fields := rds["search"].Fields
 	for name := range fields {
 		valid := false
@@ -235,7 +317,8 @@ func checkSearchFields(rds map[string]siteadapt.RequestDefinition) {
 			}
 		}
 		if !valid {
-			panic(fmt.Errorf("为了适配文件的简洁性，强制要求 field 按照规则配置，搜索字段不合法: %s", name))
+			return fmt.Errorf("为了适配文件的简洁性，强制要求 field 按照规则配置，搜索字段不合法: %s", name)
 		}
 	}
+	return nil
 }
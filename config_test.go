@@ -0,0 +1,36 @@
+package btsite_test
+
+import (
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadConfigSkipsBrokenSite(t *testing.T) {
+	fsys := fstest.MapFS{
+		"commons/README.md": {Data: []byte("公共配置")},
+		"sites/broken.json": {Data: []byte("{")},
+	}
+	conf, err := btsite.LoadConfig(fsys)
+	if conf == nil {
+		t.Fatalf("LoadConfig() conf = nil, err = %v", err)
+	}
+	var errs btsite.ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("LoadConfig() err = %v, want ConfigErrors", err)
+	}
+	if len(errs) != 1 || errs[0].File != "sites/broken.json" {
+		t.Errorf("LoadConfig() errs = %v, want sites/broken.json", errs)
+	}
+	if len(conf.Configs) != 0 {
+		t.Errorf("LoadConfig() configs = %d, want 0", len(conf.Configs))
+	}
+}
+
+func TestLoadConfigMissingDir(t *testing.T) {
+	conf, err := btsite.LoadConfig(fstest.MapFS{})
+	if err == nil || conf != nil {
+		t.Errorf("LoadConfig() = %v, %v, want nil config and error", conf, err)
+	}
+}
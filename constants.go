@@ -22,6 +22,9 @@ type siteSchema string
 const (
 	siteSchemaNexusPHP siteSchema = "NexusPHP"
 	siteSchemaMTorrent siteSchema = "mTorrent"
+	siteSchemaGazelle  siteSchema = "Gazelle"
+	siteSchemaUNIT3D   siteSchema = "UNIT3D"
+	siteSchemaDiscuz   siteSchema = "Discuz"
 )
 
 type SignInCode int
@@ -33,6 +36,42 @@ const (
 	SignInCodeNeedLogin SignInCode = 3 // 未登录
 )
 
+// Promotion 促销状态，用于搜索过滤
+type Promotion string
+
+const (
+	PromotionAny     Promotion = ""        // 不限
+	PromotionNormal  Promotion = "normal"  // 无促销
+	PromotionFree    Promotion = "free"    // 免费
+	Promotion2X      Promotion = "2x"      // 2 倍上传
+	Promotion2XFree  Promotion = "2xfree"  // 2 倍上传且免费
+	PromotionHalf    Promotion = "half"    // 50% 下载
+	Promotion2XHalf  Promotion = "2xhalf"  // 2 倍上传且 50% 下载
+	PromotionThirty  Promotion = "thirty"  // 30% 下载
+	PromotionSeventy Promotion = "seventy" // 70% 下载
+)
+
+// SortField 搜索结果排序字段
+type SortField string
+
+const (
+	SortByDefault  SortField = ""         // 站点默认排序
+	SortByTime     SortField = "time"     // 发布时间
+	SortBySize     SortField = "size"     // 体积
+	SortBySeeders  SortField = "seeders"  // 做种数
+	SortByLeechers SortField = "leechers" // 下载数
+	SortByGrabs    SortField = "grabs"    // 完成数
+	SortByTitle    SortField = "title"    // 标题
+)
+
+// SortOrder 排序方向
+type SortOrder string
+
+const (
+	SortDesc SortOrder = "" // 降序，默认
+	SortAsc  SortOrder = "asc"
+)
+
 var Movie = MediaType{
 	Code: "movie",
 	Name: "电影",
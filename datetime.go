@@ -0,0 +1,104 @@
+package btsite
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	offsetRegexp   = regexp.MustCompile(`^(?:UTC|GMT)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+	durationRegexp = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(年|个月|月|周|天|日|小时|时|分钟|分|秒|years?|yrs?|months?|mo|weeks?|wk|days?|d|hours?|hrs?|h|minutes?|mins?|m|seconds?|secs?|s)`)
+	// 已加载的时区，key 为配置的 timezone
+	locations sync.Map
+)
+
+// 时长单位，月按 30 天、年按 365 天计算
+var durationUnits = map[string]time.Duration{
+	"年": 365 * 24 * time.Hour, "year": 365 * 24 * time.Hour, "yr": 365 * 24 * time.Hour,
+	"个月": 30 * 24 * time.Hour, "月": 30 * 24 * time.Hour, "month": 30 * 24 * time.Hour, "mo": 30 * 24 * time.Hour,
+	"周": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "wk": 7 * 24 * time.Hour,
+	"天": 24 * time.Hour, "日": 24 * time.Hour, "day": 24 * time.Hour, "d": 24 * time.Hour,
+	"小时": time.Hour, "时": time.Hour, "hour": time.Hour, "hr": time.Hour, "h": time.Hour,
+	"分钟": time.Minute, "分": time.Minute, "minute": time.Minute, "min": time.Minute, "m": time.Minute,
+	"秒": time.Second, "second": time.Second, "sec": time.Second, "s": time.Second,
+}
+
+// loadLocation 解析站点配置的时区，支持 IANA 名称如 Asia/Shanghai，和 UTC 偏移如 +08:00、UTC+8，为空时使用本地时区
+func loadLocation(timezone string) (*time.Location, error) {
+	timezone = strings.TrimSpace(timezone)
+	if timezone == "" {
+		return time.Local, nil
+	}
+	if loc, ok := locations.Load(timezone); ok {
+		return loc.(*time.Location), nil
+	}
+	var loc *time.Location
+	if m := offsetRegexp.FindStringSubmatch(timezone); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("时区偏移超出范围: %s", timezone)
+		}
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		loc = time.FixedZone(timezone, offset)
+	} else {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, err
+		}
+	}
+	locations.Store(timezone, loc)
+	return loc, nil
+}
+
+// siteLocation 站点的时区，未配置或配置错误时使用本地时区，配置错误由 Validate 报告
+func siteLocation(sc Config) *time.Location {
+	loc, err := loadLocation(sc.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// parseTime 解析时间为 unix 时间戳，单位秒，支持 unix 时间戳、2006-01-02 15:04:05、Discuz 的 2024-1-2 15:04 等常见格式，
+// 不带时区的时间按 loc 解析，无法解析时返回 0
+func parseTime(s string, loc *time.Location) int64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	for _, layout := range []string{time.DateTime, "2006-1-2 15:04", time.RFC3339, time.RFC1123Z, time.RFC1123, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
+
+// parseDuration 解析站点显示的时长，如 3天12时、1年2月、5 days 3 hours，各单位累加，无法解析时返回 0
+func parseDuration(s string) time.Duration {
+	var d time.Duration
+	for _, m := range durationRegexp.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		unit := strings.ToLower(m[2])
+		if _, ok := durationUnits[unit]; !ok {
+			// 英文单位的复数形式
+			unit = strings.TrimSuffix(unit, "s")
+		}
+		d += time.Duration(n * float64(durationUnits[unit]))
+	}
+	return d
+}
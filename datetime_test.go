@@ -0,0 +1,69 @@
+package btsite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	loc, err := loadLocation("+08:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]int64{
+		"1714550400":                      1714550400,
+		"2024-05-01 16:00:00":             1714550400,
+		"2024-5-1 16:00":                  1714550400,
+		"2024-05-01T08:00:00Z":            1714550400,
+		"2024-05-01T08:00:00.5Z":          1714550400,
+		"Wed, 01 May 2024 16:00:00 +0800": 1714550400,
+		"永久":                              0,
+	}
+	for in, want := range tests {
+		if got := parseTime(in, loc); got != want {
+			t.Errorf("parseTime(%q) = %d, want %d", in, got, want)
+		}
+	}
+	want := time.Date(2024, 5, 1, 16, 0, 0, 0, time.Local).Unix()
+	if got := parseTime("2024-05-01 16:00:00", siteLocation(Config{})); got != want {
+		t.Errorf("parseTime(local) = %d, want %d", got, want)
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	for in, offset := range map[string]int{"+08:00": 8 * 3600, "UTC+8": 8 * 3600, "-0530": -(5*3600 + 30*60), "Asia/Shanghai": 8 * 3600} {
+		loc, err := loadLocation(in)
+		if err != nil {
+			t.Errorf("loadLocation(%q) error: %v", in, err)
+			continue
+		}
+		if _, got := time.Date(2024, 5, 1, 0, 0, 0, 0, loc).Zone(); got != offset {
+			t.Errorf("loadLocation(%q) offset = %d, want %d", in, got, offset)
+		}
+	}
+	for _, in := range []string{"Mars/Olympus", "+25:00"} {
+		if _, err := loadLocation(in); err == nil {
+			t.Errorf("loadLocation(%q) want error", in)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"3天12时":          84 * time.Hour,
+		"3天12小时30分钟":     84*time.Hour + 30*time.Minute,
+		"1年2月":           (365 + 60) * 24 * time.Hour,
+		"2周":             14 * 24 * time.Hour,
+		"5 days 3 hours": 123 * time.Hour,
+		"1h30m":          90 * time.Minute,
+		"12 mins":        12 * time.Minute,
+		"1.5天":           36 * time.Hour,
+		"":               0,
+		"已完成":            0,
+	}
+	for in, want := range tests {
+		if got := parseDuration(in); got != want {
+			t.Errorf("parseDuration(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
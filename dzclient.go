@@ -0,0 +1,289 @@
+package btsite
+
+import (
+	"context"
+	"errors"
+	"github.com/heibizi/go-siteadapt"
+	"golang.org/x/text/encoding/htmlindex"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// dzClient Discuz 客户端，种子为论坛帖子，签到使用 dsu_paulsign 插件
+	dzClient struct {
+		site *Site
+		*NexusPHPClient
+	}
+	// dzThread 帖子
+	dzThread struct {
+		ID        string `mapstructure:"id,omitempty"`
+		Category  string `mapstructure:"category,omitempty"`
+		Title     string `mapstructure:"title,omitempty"`
+		Details   string `mapstructure:"details,omitempty"`
+		DateAdded string `mapstructure:"date_added,omitempty"`
+	}
+)
+
+const (
+	requestIdDZLatestThreads requestId = "latest_threads"
+	requestIdDZSignInPage    requestId = "sign_in_page"
+)
+
+var (
+	dzFormhashRegexp = regexp.MustCompile(`name="formhash"\s+value="([0-9a-zA-Z]+)"|formhash=([0-9a-zA-Z]+)`)
+	dzUidRegexp      = regexp.MustCompile(`discuz_uid\s*=\s*'(\d+)'`)
+	dzMessageRegexp  = regexp.MustCompile(`<div class="c">\s*([^<]+?)\s*<`)
+	// XML 声明的 encoding 和 HTML 的 meta charset
+	dzCharsetRegexp = regexp.MustCompile(`(?i)(?:encoding|charset)\s*=\s*["']?([\w-]+)`)
+)
+
+func (c *dzClient) UserBasicInfo(ctx context.Context) (UserBasicInfo, error) {
+	var ud UserBasicInfo
+	err := data(requestSiteParams{
+		ctx:   ctx,
+		site:  c.site,
+		reqId: requestIdUserBasicInfo,
+	}, &ud, nil)
+	if err != nil {
+		return ud, newError(c.site, err, "解析基础信息失败")
+	}
+	if len(ud.ID) == 0 {
+		ud.ID = c.site.UserId
+	}
+	if ud.Ratio == 0 && ud.Downloaded > 0 {
+		ud.Ratio = float64(int(float64(ud.Uploaded)/float64(ud.Downloaded)*1000)) / 1000
+	}
+	return ud, nil
+}
+
+func (c *dzClient) Search(ctx context.Context, searchParams SearchParams) ([]SearchTorrent, error) {
+	site := c.site
+	sc, err := siteConfig(site)
+	if err != nil {
+		return nil, newError(site, err, "未获取到站点配置")
+	}
+	// 不支持按编号搜索
+	local := localFilter{promotion: true, imdb: true, douban: true}
+	if err := idSearchError(site, searchParams, local); err != nil {
+		return nil, err
+	}
+	// Discuz 从 1 开始
+	params := url.Values{
+		"page": {strconv.Itoa(searchParams.Page + 1)},
+	}
+	// 无关键字时取最新帖子
+	reqId := requestIdDZLatestThreads
+	if len(searchParams.Keyword) > 0 {
+		reqId = requestIdSearch
+		params.Set("srchtxt", searchParams.Keyword)
+		// 分类对应版块 id
+		for _, id := range searchCategories(sc, searchParams) {
+			params.Add("srchfid[]", id)
+		}
+	}
+	var threads []dzThread
+	domain := ""
+	err = list(requestSiteParams{
+		ctx:    ctx,
+		site:   site,
+		reqId:  reqId,
+		params: params,
+	}, &threads, func(result siteadapt.ListResult) {
+		domain = result.Domain
+	})
+	if err != nil {
+		return nil, newError(site, err, "搜索异常")
+	}
+	loc := siteLocation(sc)
+	var searchTorrents []SearchTorrent
+	for _, thread := range threads {
+		pageUrl := thread.Details
+		if !strings.HasPrefix(pageUrl, "http") {
+			if pageUrl, err = JoinURL(domain, thread.Details); err != nil {
+				return nil, newError(site, err, "搜索种子拼接 details 错误")
+			}
+		}
+		searchTorrents = append(searchTorrents, SearchTorrent{
+			ID:                   thread.ID,
+			Category:             thread.Category,
+			Title:                thread.Title,
+			PageURL:              pageUrl,
+			DownloadVolumeFactor: 1,
+			UploadVolumeFactor:   1,
+			PubDate:              parseTime(thread.DateAdded, loc),
+			PubDateRaw:           thread.DateAdded,
+		})
+	}
+	return searchResult(ctx, searchParams, searchTorrents, local), nil
+}
+
+// UserDetails Discuz 没有等级、加入时间等用户详情的请求定义，返回 ErrUnsupported
+func (c *dzClient) UserDetails(ctx context.Context) (UserDetails, error) {
+	return UserDetails{}, unsupported(c.site, "用户详情")
+}
+
+// SeedingStatistics 种子为论坛帖子，没有做种信息，返回 ErrUnsupported
+func (c *dzClient) SeedingStatistics(ctx context.Context) (SeedingStatistics, error) {
+	return SeedingStatistics{}, unsupported(c.site, "做种统计")
+}
+
+// LatestNotice Discuz 没有统一的公告页面，返回 ErrUnsupported
+func (c *dzClient) LatestNotice(ctx context.Context) (*Notice, error) {
+	return nil, unsupported(c.site, "公告")
+}
+
+// Details 种子为论坛帖子，没有促销和做种人数，返回 ErrUnsupported
+func (c *dzClient) Details(ctx context.Context, id string) (TorrentDetail, error) {
+	return TorrentDetail{}, unsupported(c.site, "种子详情")
+}
+
+func (c *dzClient) MyHr(ctx context.Context) ([]HrTorrent, error) {
+	// 无 hr
+	return nil, nil
+}
+
+func (c *dzClient) UnreadMessages(ctx context.Context, detail bool) ([]Message, error) {
+	var o []Message
+	domain := ""
+	err := list(requestSiteParams{
+		ctx:   ctx,
+		site:  c.site,
+		reqId: requestIdUnreadMessages,
+	}, &o, func(result siteadapt.ListResult) {
+		domain = result.Domain
+	})
+	if err != nil {
+		return nil, newError(c.site, err, "未读消息列表异常")
+	}
+	if detail {
+		for i := range o {
+			message := &o[i]
+			detailUrl, err := JoinURL(domain, message.Link)
+			if err != nil {
+				return nil, err
+			}
+			detail, err := c.unreadMessageDetail(ctx, detailUrl)
+			if err != nil {
+				return nil, err
+			}
+			message.Content = detail.Content
+		}
+	}
+	return o, nil
+}
+
+// SignIn 签到，先从签到页面获取 formhash，再提交签到表单，响应按页面声明的编码解码后匹配
+func (c *dzClient) SignIn(ctx context.Context) (SignInResult, error) {
+	page, err := c.rawText(WithoutCache(ctx), requestIdDZSignInPage, nil)
+	if err != nil && !errors.Is(err, ErrNotLoggedIn) {
+		return SignInResult{}, newError(c.site, err, "获取签到页面异常")
+	}
+	if m := dzUidRegexp.FindStringSubmatch(page); err != nil || m == nil || m[1] == "0" {
+		return SignInResult{
+			Code:    SignInCodeNeedLogin,
+			Message: "未登录",
+		}, nil
+	}
+	if strings.Contains(page, "已经签到") {
+		return SignInResult{
+			Code:    SignInCodeSigned,
+			Message: "今日已签到",
+		}, nil
+	}
+	formhash := dzFormhash(page)
+	if len(formhash) == 0 {
+		return SignInResult{
+			Code:    SignInCodeFailure,
+			Message: "签到失败，未获取到 formhash，请检查该站点是否已安装签到插件",
+		}, nil
+	}
+	r, err := c.rawText(ctx, requestIdSignIn, url.Values{
+		"formhash":  {formhash},
+		"qdxq":      {"kx"},
+		"qdmode":    {"3"},
+		"todaysay":  {""},
+		"fastreply": {"0"},
+	})
+	if err != nil {
+		return SignInResult{}, newError(c.site, err, "签到异常")
+	}
+	switch {
+	case strings.Contains(r, "签到成功"):
+		return SignInResult{
+			Code:    SignInCodeSuccess,
+			Message: "签到成功",
+		}, nil
+	case strings.Contains(r, "已经签到"):
+		return SignInResult{
+			Code:    SignInCodeSigned,
+			Message: "今日已签到",
+		}, nil
+	}
+	message := "请检查该站点是否已适配"
+	if m := dzMessageRegexp.FindStringSubmatch(r); m != nil {
+		message = m[1]
+	}
+	return SignInResult{
+		Code:    SignInCodeFailure,
+		Message: "签到失败，" + message,
+	}, nil
+}
+
+// rawText 请求不解析的页面并解码为 UTF-8 文本，formData 不为空时作为表单提交
+func (c *dzClient) rawText(ctx context.Context, reqId requestId, formData url.Values) (string, error) {
+	var b []byte
+	err := raw(requestSiteParams{
+		ctx:      ctx,
+		site:     c.site,
+		reqId:    reqId,
+		formData: formData,
+	}, func(result siteadapt.RawResult) {
+		b = result.Data
+	})
+	if err != nil {
+		return "", err
+	}
+	encoding := ""
+	if sc, err := siteConfig(c.site); err == nil {
+		encoding = sc.Encoding
+	}
+	return dzDecode(b, encoding), nil
+}
+
+// dzDecode 按响应中声明的编码解码，未声明时使用站点配置的编码，GBK 站点的签到响应为 GBK 编码
+func dzDecode(b []byte, encoding string) string {
+	head := b
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if m := dzCharsetRegexp.FindSubmatch(head); m != nil {
+		encoding = string(m[1])
+	}
+	if len(encoding) == 0 {
+		return string(b)
+	}
+	e, err := htmlindex.Get(encoding)
+	if err != nil {
+		return string(b)
+	}
+	d, err := e.NewDecoder().Bytes(b)
+	if err != nil {
+		return string(b)
+	}
+	return string(d)
+}
+
+// dzFormhash 从页面中提取 formhash，表单隐藏域和链接参数两种形式
+func dzFormhash(page string) string {
+	m := dzFormhashRegexp.FindStringSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	if len(m[1]) > 0 {
+		return m[1]
+	}
+	return m[2]
+}
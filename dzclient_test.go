@@ -0,0 +1,184 @@
+package btsite_test
+
+import (
+	"context"
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newDiscuzClient page 为签到页面，result 为提交签到的响应，提交的 formhash 不正确时返回 404
+func newDiscuzClient(t *testing.T, page string, result string) btsite.ClientContext {
+	c, _ := newFixtureClient(t, "Discuz", "discuz", func(r *http.Request) string {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/search.php":
+			return "search.html"
+		case "/forum.php":
+			if q.Get("mod") == "guide" && q.Get("view") == "newthread" {
+				return "newthread.html"
+			}
+			return ""
+		case "/home.php":
+			switch {
+			case q.Get("ac") == "credit":
+				return "credit.html"
+			case q.Get("filter") == "newpm":
+				return "pm.html"
+			case q.Get("subop") == "view":
+				return "pm_view.html"
+			}
+			return ""
+		}
+		if r.URL.Path != "/plugin.php" || q.Get("id") != "dsu_paulsign:sign" {
+			return ""
+		}
+		if q.Get("operation") != "qiandao" {
+			return page
+		}
+		if r.Method != http.MethodPost || r.PostFormValue("formhash") != "a1b2c3d4" {
+			return ""
+		}
+		return result
+	})
+	return c
+}
+
+func TestDiscuzSignIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		page   string
+		result string
+		want   btsite.SignInCode
+	}{
+		{"success", "sign.html", "sign_success.xml", btsite.SignInCodeSuccess},
+		{"failure", "sign.html", "sign_failure.xml", btsite.SignInCodeFailure},
+		{"signed", "signed.html", "", btsite.SignInCodeSigned},
+		{"gbk success", "sign.html", "sign_success_gbk.xml", btsite.SignInCodeSuccess},
+		{"gbk signed", "signed_gbk.html", "", btsite.SignInCodeSigned},
+		{"guest", "guest.html", "", btsite.SignInCodeNeedLogin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newDiscuzClient(t, tt.page, tt.result)
+			r, err := c.SignIn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Code != tt.want {
+				t.Errorf("got %+v, want code %d", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscuzSignInFailureMessage(t *testing.T) {
+	for _, result := range []string{"sign_failure.xml", "sign_failure_gbk.xml"} {
+		c := newDiscuzClient(t, "sign.html", result)
+		r, err := c.SignIn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "签到失败，当前时间不在签到时间段内"; r.Message != want {
+			t.Errorf("%s: message = %q, want %q", result, r.Message, want)
+		}
+	}
+}
+
+func TestDiscuzUserBasicInfo(t *testing.T) {
+	c := newDiscuzClient(t, "", "")
+	info, err := c.UserBasicInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := btsite.UserBasicInfo{
+		IsLogin:            true,
+		ID:                 "1024",
+		Name:               "fixture",
+		UnreadMessageCount: 2,
+		Ratio:              3,
+		Uploaded:           1649267441664,
+		Downloaded:         549755813888,
+		Bonus:              1234.5,
+	}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestDiscuzSearch(t *testing.T) {
+	c := newDiscuzClient(t, "", "")
+	torrents, err := c.Search(context.Background(), btsite.SearchParams{Keyword: "matrix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 2 {
+		t.Fatalf("got %d torrents, want 2", len(torrents))
+	}
+	first := torrents[0]
+	if first.ID != "3001" || first.Title != "The Matrix 1999 1080p BluRay x264" || first.Category != "电影" {
+		t.Errorf("got %+v", first)
+	}
+	if !strings.HasSuffix(first.PageURL, "/forum.php?mod=viewthread&tid=3001&highlight=matrix") {
+		t.Errorf("PageURL = %s", first.PageURL)
+	}
+	if want := time.Date(2024, 1, 2, 15, 4, 0, 0, time.Local).Unix(); first.PubDate != want {
+		t.Errorf("PubDate = %d, want %d", first.PubDate, want)
+	}
+}
+
+func TestDiscuzLatestThreads(t *testing.T) {
+	c := newDiscuzClient(t, "", "")
+	torrents, err := c.Search(context.Background(), btsite.SearchParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 2 {
+		t.Fatalf("got %d torrents, want 2", len(torrents))
+	}
+	first := torrents[0]
+	if first.ID != "4001" || first.Title != "Frieren S01 2023 1080p WEB-DL" || first.Category != "剧集" {
+		t.Errorf("got %+v", first)
+	}
+	if want := time.Date(2024, 5, 6, 7, 8, 0, 0, time.Local).Unix(); first.PubDate != want {
+		t.Errorf("PubDate = %d, want %d", first.PubDate, want)
+	}
+	if torrents[1].ID != "4002" || torrents[1].Category != "电影" {
+		t.Errorf("got %+v", torrents[1])
+	}
+}
+
+func TestDiscuzUnreadMessages(t *testing.T) {
+	c := newDiscuzClient(t, "", "")
+	messages, err := c.UnreadMessages(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	m := messages[0]
+	if m.ID != "7" || m.Head != "欢迎加入" || m.Content != "欢迎来到 Fixture，请阅读站规" {
+		t.Errorf("got %+v", m)
+	}
+}
+
+func TestDiscuzUnsupported(t *testing.T) {
+	c := newDiscuzClient(t, "", "")
+	ctx := context.Background()
+	if _, err := c.UserDetails(ctx); !errors.Is(err, btsite.ErrUnsupported) {
+		t.Errorf("UserDetails() err = %v, want ErrUnsupported", err)
+	}
+	if _, err := c.SeedingStatistics(ctx); !errors.Is(err, btsite.ErrUnsupported) {
+		t.Errorf("SeedingStatistics() err = %v, want ErrUnsupported", err)
+	}
+	if _, err := c.LatestNotice(ctx); !errors.Is(err, btsite.ErrUnsupported) {
+		t.Errorf("LatestNotice() err = %v, want ErrUnsupported", err)
+	}
+	if _, err := c.Details(ctx, "3001"); !errors.Is(err, btsite.ErrUnsupported) {
+		t.Errorf("Details() err = %v, want ErrUnsupported", err)
+	}
+}
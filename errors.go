@@ -0,0 +1,159 @@
+package btsite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// 异常类型，通过 errors.Is 判断
+var (
+	ErrNotLoggedIn       = errors.New("未登录或 cookie 已失效")
+	ErrSiteUnavailable   = errors.New("站点无法访问")
+	ErrChallenge         = errors.New("触发 Cloudflare 等人机验证")
+	ErrRateLimited       = errors.New("请求过于频繁")
+	ErrParse             = errors.New("解析失败")
+	ErrConfigNotFound    = errors.New("站点配置不存在")
+	ErrInvalidSchema     = errors.New("无效架构")
+	ErrUnsupportedSearch = errors.New("站点不支持该搜索条件")
+	ErrUnsupported       = errors.New("站点架构不支持该功能")
+)
+
+// errChallengePage 响应为人机验证页面，异常类型为 ErrChallenge
+var errChallengePage = errors.New("响应为人机验证页面")
+
+// SiteError 站点请求异常，通过 errors.As 获取
+type SiteError struct {
+	Site       string // 站点唯一标识
+	RequestId  string // 请求 id，自定义请求时为空
+	StatusCode int    // HTTP 状态码，未知时为 0
+	URL        string // 请求地址，未知时为空
+	Kind       error  // 异常类型，见 ErrNotLoggedIn 等，无法判断时为空
+	Err        error  // 原始异常
+}
+
+func (e *SiteError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("请求")
+	if e.RequestId != "" {
+		sb.WriteString(" " + e.RequestId)
+	}
+	sb.WriteString(" 失败")
+	if e.StatusCode > 0 {
+		sb.WriteString(fmt.Sprintf(", 状态码: %d", e.StatusCode))
+	}
+	if e.URL != "" {
+		sb.WriteString(", 地址: " + e.URL)
+	}
+	if e.Kind != nil {
+		sb.WriteString(", " + e.Kind.Error())
+	}
+	sb.WriteString(fmt.Sprintf(": %v", e.Err))
+	return sb.String()
+}
+
+// Unwrap 同时支持 errors.Is 判断异常类型和原始异常
+func (e *SiteError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// newSiteError 包装站点请求异常，根据状态码和原始异常判断异常类型
+func newSiteError(params requestSiteParams, statusCode int, url string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *SiteError
+	if errors.As(err, &se) {
+		return err
+	}
+	return &SiteError{
+		Site:       params.site.Code,
+		RequestId:  string(params.reqId),
+		StatusCode: statusCode,
+		URL:        url,
+		Kind:       errorKind(statusCode, url, err),
+		Err:        err,
+	}
+}
+
+// errorKind 判断异常类型，url 为最后一个响应的地址，重定向到登录页时为登录页地址
+func errorKind(statusCode int, url string, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, errChallengePage):
+		return ErrChallenge
+	case statusCode == http.StatusUnauthorized || isLoginUrl(url):
+		return ErrNotLoggedIn
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case (statusCode == http.StatusForbidden || statusCode == http.StatusServiceUnavailable) &&
+		(strings.Contains(msg, "cloudflare") || strings.Contains(msg, "just a moment") || strings.Contains(msg, "cf-chl")):
+		return ErrChallenge
+	case statusCode >= http.StatusInternalServerError:
+		return ErrSiteUnavailable
+	case statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices:
+		return ErrParse
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrSiteUnavailable
+	}
+	return nil
+}
+
+// Cloudflare 验证页面的特征
+var challengeMarkers = [][]byte{[]byte("/cdn-cgi/challenge-platform/"), []byte("_cf_chl_opt")}
+
+// isChallenge 判断响应是否为 Cloudflare 等人机验证页面，
+// 响应头 cf-mitigated 为 challenge，或响应体包含验证页面的脚本，验证页面的状态码可能是 200、403、503
+func isChallenge(resp *http.Response, body []byte) bool {
+	if strings.EqualFold(resp.Header.Get("Cf-Mitigated"), "challenge") {
+		return true
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return false
+	}
+	for _, marker := range challengeMarkers {
+		if bytes.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoginUrl 判断地址是否为登录页，如 NexusPHP 未登录时重定向的 login.php、
+// UNIT3D 的 /login、Discuz 的 member.php?mod=logging
+func isLoginUrl(rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil || rawUrl == "" {
+		return false
+	}
+	switch strings.ToLower(path.Base(u.Path)) {
+	case "login.php", "takelogin.php", "login", "signin":
+		return true
+	case "member.php":
+		return u.Query().Get("mod") == "logging"
+	}
+	return false
+}
+
+func newError(site *Site, err error, format string, v ...any) error {
+	return fmt.Errorf("站点(%s)%s, 异常: %w", site.Name, fmt.Sprintf(format, v...), err)
+}
+
+// unsupported 站点架构不支持的功能，返回 ErrUnsupported
+func unsupported(site *Site, feature string) error {
+	return newError(site, ErrUnsupported, "不支持%s", feature)
+}
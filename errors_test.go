@@ -0,0 +1,159 @@
+package btsite_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-siteadapt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConfigNotFound(t *testing.T) {
+	_, err := btsite.NewClient(&btsite.Site{Code: "go-btsite-missing"})
+	if !errors.Is(err, btsite.ErrConfigNotFound) {
+		t.Errorf("NewClient() err = %v, want ErrConfigNotFound", err)
+	}
+}
+
+func TestSiteErrorUnwrap(t *testing.T) {
+	cause := errors.New("bad gateway")
+	err := fmt.Errorf("站点(test)搜索异常, 异常: %w", &btsite.SiteError{
+		Site:       "test",
+		RequestId:  "search",
+		StatusCode: 502,
+		Kind:       btsite.ErrSiteUnavailable,
+		Err:        cause,
+	})
+	if !errors.Is(err, btsite.ErrSiteUnavailable) || !errors.Is(err, cause) {
+		t.Errorf("errors.Is(%v) = false, want true", err)
+	}
+	var se *btsite.SiteError
+	if !errors.As(err, &se) || se.StatusCode != 502 {
+		t.Errorf("errors.As(%v) = %+v, want status 502", err, se)
+	}
+}
+
+// newTestSite 创建使用 srv 的 NexusPHP 站点客户端，rds 为请求 id 对应的路径
+func newTestSite(t *testing.T, srv *httptest.Server, rds map[string]string) btsite.ClientContext {
+	t.Helper()
+	requests := make(map[string]siteadapt.RequestDefinition)
+	for reqId, path := range rds {
+		requests[reqId] = siteadapt.RequestDefinition{Path: path}
+	}
+	r := btsite.NewRegistry(&btsite.AdaptCfg{Configs: []btsite.Config{{
+		Config: siteadapt.Config{ID: "test", Domain: srv.URL, RequestDefinitions: requests},
+		Schema: "NexusPHP",
+	}}})
+	c, err := r.NewClientContext(&btsite.Site{Code: "test", Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// newRssSite 创建 RSS 地址为 srv 的 NexusPHP 站点客户端，RSS 由本包直接请求，可以拿到真实的状态码和重定向后的地址
+func newRssSite(t *testing.T, srv *httptest.Server, cfg btsite.Config) btsite.ClientContext {
+	t.Helper()
+	cfg.Config.ID, cfg.Schema = "rss", "NexusPHP"
+	r := btsite.NewRegistry(&btsite.AdaptCfg{Configs: []btsite.Config{cfg}})
+	c, err := r.NewClientContext(&btsite.Site{Code: "rss", Name: "rss", RssUrl: srv.URL + "/torrentrss.php"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNotLoggedInRedirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login.php" {
+			_, _ = w.Write([]byte("<form action=takelogin.php></form>"))
+			return
+		}
+		http.Redirect(w, r, "/login.php?returnto="+r.URL.Path, http.StatusFound)
+	}))
+	defer srv.Close()
+	c := newRssSite(t, srv, btsite.Config{})
+	_, err := c.Rss(context.Background())
+	var se *btsite.SiteError
+	if !errors.Is(err, btsite.ErrNotLoggedIn) || !errors.As(err, &se) || se.URL != srv.URL+"/login.php?returnto=/torrentrss.php" {
+		t.Errorf("Rss() err = %v, want ErrNotLoggedIn", err)
+	}
+}
+
+func TestFetchStatusCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	c := newRssSite(t, srv, btsite.Config{})
+	_, err := c.Rss(context.Background())
+	var se *btsite.SiteError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadGateway || !errors.Is(err, btsite.ErrSiteUnavailable) {
+		t.Errorf("Rss() err = %v, want 502 ErrSiteUnavailable", err)
+	}
+}
+
+func TestRetryBadGateway(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("<rss><channel></channel></rss>"))
+	}))
+	defer srv.Close()
+	c := newRssSite(t, srv, btsite.Config{
+		Retry: btsite.RetryConfig{RetryPolicy: btsite.RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1}},
+	})
+	if _, err := c.Rss(context.Background()); err != nil {
+		t.Errorf("Rss() err = %v, want nil after retry", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("hits = %d, want 2", got)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	const page = `<!DOCTYPE html><html><head><title>Just a moment...</title></head><body>` +
+		`<script>window._cf_chl_opt={cType: 'managed'};</script>` +
+		`<script src="/cdn-cgi/challenge-platform/h/g/orchestrate/chl_page/v1"></script></body></html>`
+	cases := map[string]http.HandlerFunc{
+		"cf-mitigated": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cf-Mitigated", "challenge")
+			w.WriteHeader(http.StatusForbidden)
+		},
+		"403": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(page))
+		},
+		"200": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+			_, _ = w.Write([]byte(page))
+		},
+	}
+	for name, handler := range cases {
+		t.Run(name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				handler(w, r)
+			}))
+			defer srv.Close()
+			c := newRssSite(t, srv, btsite.Config{
+				Retry: btsite.RetryConfig{RetryPolicy: btsite.RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1, StatusCodes: []int{403}}},
+			})
+			if _, err := c.Rss(context.Background()); !errors.Is(err, btsite.ErrChallenge) {
+				t.Errorf("Rss() err = %v, want ErrChallenge", err)
+			}
+			if got := hits.Load(); got != 1 {
+				t.Errorf("hits = %d, want 1 without retry", got)
+			}
+		})
+	}
+}
@@ -2,19 +2,21 @@ module github.com/heibizi/go-btsite
 
 go 1.22
 
-require github.com/heibizi/go-siteadapt v0.0.0-20240807112320-8f202fd7f8ed
+require (
+	github.com/andybalholm/cascadia v1.3.2
+	github.com/antchfx/xpath v1.3.1
+	github.com/heibizi/go-siteadapt v0.0.0-20240807112320-8f202fd7f8ed
+	github.com/tidwall/gjson v1.17.3
+	golang.org/x/text v0.14.0
+)
 
 require (
 	github.com/PuerkitoBio/goquery v1.9.2 // indirect
-	github.com/andybalholm/cascadia v1.3.2 // indirect
 	github.com/antchfx/htmlquery v1.3.2 // indirect
-	github.com/antchfx/xpath v1.3.1 // indirect
 	github.com/golang-module/carbon/v2 v2.3.12 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	github.com/tidwall/gjson v1.17.3 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	golang.org/x/net v0.24.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )
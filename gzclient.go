@@ -0,0 +1,616 @@
+package btsite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heibizi/go-siteadapt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// gzClient Gazelle 客户端，通过 ajax.php 的 JSON 接口获取数据
+	gzClient struct {
+		site *Site
+		*NexusPHPClient
+
+		mu      sync.Mutex
+		idIndex *gzIndex // 缓存的 index，只使用其中的用户 id、authkey 和 passkey
+	}
+	// gzResponse ajax.php 响应
+	gzResponse[T any] struct {
+		Status   string `json:"status"`
+		Error    string `json:"error"`
+		Response T      `json:"response"`
+	}
+	// gzBool 兼容 true、false、"1"、"0"、1、0 格式的布尔值
+	gzBool bool
+	// gzString 兼容字符串和数字格式的文本，不同 Gazelle 站点的部分字段类型不一致
+	gzString string
+	gzIndex  struct {
+		Username      string `json:"username"`
+		ID            int64  `json:"id"`
+		AuthKey       string `json:"authkey"`
+		PassKey       string `json:"passkey"`
+		Notifications struct {
+			Messages      int `json:"messages"`
+			Notifications int `json:"notifications"`
+		} `json:"notifications"`
+		UserStats struct {
+			Uploaded    int64       `json:"uploaded"`
+			Downloaded  int64       `json:"downloaded"`
+			Ratio       json.Number `json:"ratio"`
+			Class       string      `json:"class"`
+			BonusPoints json.Number `json:"bonusPoints"`
+		} `json:"userstats"`
+	}
+	gzUser struct {
+		Username string `json:"username"`
+		Stats    struct {
+			JoinedDate string `json:"joinedDate"`
+			LastAccess string `json:"lastAccess"`
+		} `json:"stats"`
+		Personal struct {
+			Class string `json:"class"`
+		} `json:"personal"`
+		Community struct {
+			Seeding json.Number `json:"seeding"`
+		} `json:"community"`
+	}
+	gzTorrent struct {
+		TorrentID           json.Number `json:"torrentId"`
+		Media               string      `json:"media"`
+		Format              string      `json:"format"`
+		Encoding            string      `json:"encoding"`
+		RemasterTitle       string      `json:"remasterTitle"`
+		Time                string      `json:"time"`
+		Size                json.Number `json:"size"`
+		Snatches            json.Number `json:"snatches"`
+		Seeders             json.Number `json:"seeders"`
+		Leechers            json.Number `json:"leechers"`
+		IsFreeleech         gzBool      `json:"isFreeleech"`
+		IsNeutralLeech      gzBool      `json:"isNeutralLeech"`
+		IsPersonalFreeleech gzBool      `json:"isPersonalFreeleech"`
+	}
+	// gzGroup 种子组，音乐类站点一个组包含多个种子，其他类别的种子直接平铺在组上
+	gzGroup struct {
+		GroupID     json.Number `json:"groupId"`
+		GroupName   string      `json:"groupName"`
+		Artist      string      `json:"artist"`
+		GroupYear   gzString    `json:"groupYear"`
+		ReleaseType gzString    `json:"releaseType"`
+		Category    gzString    `json:"category"`
+		Tags        []string    `json:"tags"`
+		GroupTime   gzString    `json:"groupTime"`
+		Torrents    []gzTorrent `json:"torrents"`
+		gzTorrent
+	}
+	gzBrowse struct {
+		CurrentPage int       `json:"currentPage"`
+		Pages       int       `json:"pages"`
+		Results     []gzGroup `json:"results"`
+	}
+	gzInbox struct {
+		Messages []struct {
+			ConvID  json.Number `json:"convId"`
+			Subject string      `json:"subject"`
+			Unread  gzBool      `json:"unread"`
+			Date    string      `json:"date"`
+		} `json:"messages"`
+	}
+	gzConversation struct {
+		Messages []struct {
+			Body     string `json:"body"`
+			SentDate string `json:"sentDate"`
+		} `json:"messages"`
+	}
+	gzAnnouncements struct {
+		Announcements []struct {
+			Title    string `json:"title"`
+			Body     string `json:"body"`
+			NewsTime string `json:"newsTime"`
+		} `json:"announcements"`
+	}
+	// gzNotifications 订阅通知，符合用户通知过滤器的新种子
+	gzNotifications struct {
+		Results []struct {
+			TorrentID        json.Number `json:"torrentId"`
+			GroupID          json.Number `json:"groupId"`
+			GroupName        string      `json:"groupName"`
+			GroupYear        gzString    `json:"groupYear"`
+			Size             json.Number `json:"size"`
+			Format           string      `json:"format"`
+			Encoding         string      `json:"encoding"`
+			Media            string      `json:"media"`
+			RemasterTitle    string      `json:"remasterTitle"`
+			NotificationTime string      `json:"notificationTime"`
+		} `json:"results"`
+	}
+	gzTorrentDetail struct {
+		Torrent struct {
+			Seeders     int    `json:"seeders"`
+			FreeTorrent gzBool `json:"freeTorrent"`
+		} `json:"torrent"`
+	}
+)
+
+const (
+	requestIdGZIndex         requestId = "index"
+	requestIdGZBrowse        requestId = "browse"
+	requestIdGZUser          requestId = "user"
+	requestIdGZInbox         requestId = "inbox"
+	requestIdGZAnnouncements requestId = "announcements"
+	requestIdGZTorrent       requestId = "torrent"
+	requestIdGZNotifications requestId = "notifications"
+)
+
+// Gazelle 搜索的 order_by 参数，不支持按标题排序
+var gzSortFields = map[SortField]string{
+	SortByTime:     "time",
+	SortBySize:     "size",
+	SortByGrabs:    "snatched",
+	SortBySeeders:  "seeders",
+	SortByLeechers: "leechers",
+}
+
+func (b *gzBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true", "1":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+func (s *gzString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*s = gzString(str)
+		return nil
+	}
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+	*s = gzString(data)
+	return nil
+}
+
+// gzInt 转换数字，格式错误时为 0
+func gzInt(n json.Number) int64 {
+	i, err := n.Int64()
+	if err != nil {
+		f, _ := n.Float64()
+		return int64(f)
+	}
+	return i
+}
+
+func (c *gzClient) UserBasicInfo(ctx context.Context) (UserBasicInfo, error) {
+	index, err := c.fetchIndex(ctx)
+	if err != nil {
+		return UserBasicInfo{}, err
+	}
+	ratio, _ := index.UserStats.Ratio.Float64()
+	bonus, _ := index.UserStats.BonusPoints.Float64()
+	return UserBasicInfo{
+		IsLogin:            len(index.Username) > 0,
+		ID:                 strconv.FormatInt(index.ID, 10),
+		Name:               index.Username,
+		UnreadMessageCount: index.Notifications.Messages,
+		Ratio:              ratio,
+		Uploaded:           index.UserStats.Uploaded,
+		Downloaded:         index.UserStats.Downloaded,
+		Bonus:              bonus,
+	}, nil
+}
+
+func (c *gzClient) UserDetails(ctx context.Context) (UserDetails, error) {
+	user, err := c.user(ctx)
+	if err != nil {
+		return UserDetails{}, err
+	}
+	return UserDetails{
+		Level:        user.Personal.Class,
+		JoinAt:       siteadapt.GetTimeStamp(user.Stats.JoinedDate),
+		LastAccessed: siteadapt.GetTimeStamp(user.Stats.LastAccess),
+	}, nil
+}
+
+func (c *gzClient) Search(ctx context.Context, searchParams SearchParams) ([]SearchTorrent, error) {
+	// 不支持按编号搜索
+	local := localFilter{promotion: true, imdb: true, douban: true}
+	if err := idSearchError(c.site, searchParams, local); err != nil {
+		return nil, err
+	}
+	index, err := c.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{
+		"action": {string(requestIdGZBrowse)},
+		// Gazelle 从 1 开始
+		"page": {strconv.Itoa(searchParams.Page + 1)},
+	}
+	if len(searchParams.Keyword) > 0 {
+		params.Set("searchstr", searchParams.Keyword)
+	}
+	sc, err := siteConfig(c.site)
+	if err != nil {
+		return nil, newError(c.site, err, "未获取到站点配置")
+	}
+	// 分类对应 filter_cat[id]=1
+	for _, id := range searchCategories(sc, searchParams) {
+		params.Set("filter_cat["+id+"]", "1")
+	}
+	if orderBy, ok := gzSortFields[searchParams.SortBy]; ok {
+		params.Set("order_by", orderBy)
+		params.Set("order_way", "desc")
+		if searchParams.SortOrder == SortAsc {
+			params.Set("order_way", "asc")
+		}
+	}
+	var browse gzBrowse
+	if err := gzAjax(ctx, c.site, requestIdGZBrowse, params, &browse); err != nil {
+		return nil, newError(c.site, err, "搜索异常")
+	}
+	domain, err := SiteHelper.GetDomain(*c.site)
+	if err != nil {
+		return nil, err
+	}
+	loc := siteLocation(sc)
+	var searchTorrents []SearchTorrent
+	for _, group := range browse.Results {
+		torrents := group.Torrents
+		// 非音乐类别没有 torrents，种子信息在组上
+		if len(torrents) == 0 {
+			torrents = []gzTorrent{group.gzTorrent}
+		}
+		for _, t := range torrents {
+			search, err := c.searchTorrent(domain, index, group, t, loc)
+			if err != nil {
+				return nil, newError(c.site, err, "搜索种子拼接链接错误")
+			}
+			searchTorrents = append(searchTorrents, search)
+		}
+	}
+	return searchResult(ctx, searchParams, searchTorrents, local), nil
+}
+
+// searchTorrent 将种子组中的种子转换为搜索种子，发布时间按 loc 解析
+func (c *gzClient) searchTorrent(domain string, index gzIndex, group gzGroup, t gzTorrent, loc *time.Location) (SearchTorrent, error) {
+	id := t.TorrentID.String()
+	pageUrl, err := JoinURL(domain, fmt.Sprintf("torrents.php?id=%s&torrentid=%s", group.GroupID, id))
+	if err != nil {
+		return SearchTorrent{}, err
+	}
+	enclosure, err := gzDownloadUrl(domain, id, index)
+	if err != nil {
+		return SearchTorrent{}, err
+	}
+	title := group.GroupName
+	if len(group.Artist) > 0 {
+		title = group.Artist + " - " + title
+	}
+	if year := string(group.GroupYear); len(year) > 0 && year != "0" {
+		title = fmt.Sprintf("%s [%s]", title, year)
+	}
+	var edition []string
+	for _, s := range []string{t.RemasterTitle, t.Format, t.Encoding, t.Media} {
+		if len(s) > 0 {
+			edition = append(edition, s)
+		}
+	}
+	category := string(group.ReleaseType)
+	if len(category) == 0 {
+		category = string(group.Category)
+	}
+	pubDate := t.Time
+	if len(pubDate) == 0 {
+		pubDate = string(group.GroupTime)
+	}
+	downloadVolumeFactor, uploadVolumeFactor := 1.0, 1.0
+	if t.IsFreeleech || t.IsPersonalFreeleech {
+		downloadVolumeFactor = 0
+	}
+	// 中性种子上传下载都不计算
+	if t.IsNeutralLeech {
+		downloadVolumeFactor, uploadVolumeFactor = 0, 0
+	}
+	return SearchTorrent{
+		ID:                   id,
+		Category:             category,
+		Title:                title,
+		Description:          strings.Join(edition, " / "),
+		PageURL:              pageUrl,
+		Enclosure:            enclosure,
+		Grabs:                int(gzInt(t.Snatches)),
+		Seeders:              int(gzInt(t.Seeders)),
+		Leechers:             int(gzInt(t.Leechers)),
+		Size:                 gzInt(t.Size),
+		DownloadVolumeFactor: downloadVolumeFactor,
+		UploadVolumeFactor:   uploadVolumeFactor,
+		PubDate:              parseTime(pubDate, loc),
+		PubDateRaw:           pubDate,
+		Labels:               group.Tags,
+	}, nil
+}
+
+// SeedingStatistics 接口只有做种数量，没有做种体积
+func (c *gzClient) SeedingStatistics(ctx context.Context) (SeedingStatistics, error) {
+	user, err := c.user(ctx)
+	if err != nil {
+		return SeedingStatistics{}, err
+	}
+	return SeedingStatistics{Count: int(gzInt(user.Community.Seeding))}, nil
+}
+
+func (c *gzClient) MyHr(ctx context.Context) ([]HrTorrent, error) {
+	// 无 hr
+	return nil, nil
+}
+
+func (c *gzClient) UnreadMessages(ctx context.Context, detail bool) ([]Message, error) {
+	var inbox gzInbox
+	err := gzAjax(ctx, c.site, requestIdGZInbox, url.Values{
+		"action": {string(requestIdGZInbox)},
+		"type":   {"inbox"},
+	}, &inbox)
+	if err != nil {
+		return nil, newError(c.site, err, "未读消息列表异常")
+	}
+	var messages []Message
+	for _, m := range inbox.Messages {
+		if !m.Unread {
+			continue
+		}
+		message := Message{
+			ID:   m.ConvID.String(),
+			Head: m.Subject,
+			Date: m.Date,
+			Link: "inbox.php?action=viewconv&id=" + m.ConvID.String(),
+		}
+		if detail {
+			var conv gzConversation
+			err := gzAjax(ctx, c.site, requestIdGZInbox, url.Values{
+				"action": {string(requestIdGZInbox)},
+				"type":   {"viewconv"},
+				"id":     {message.ID},
+			}, &conv)
+			if err != nil {
+				return nil, newError(c.site, err, "用户未读消息详情失败")
+			}
+			if n := len(conv.Messages); n > 0 {
+				message.Content = conv.Messages[n-1].Body
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (c *gzClient) LatestNotice(ctx context.Context) (*Notice, error) {
+	var announcements gzAnnouncements
+	err := gzAjax(ctx, c.site, requestIdGZAnnouncements, url.Values{
+		"action": {string(requestIdGZAnnouncements)},
+	}, &announcements)
+	if err != nil {
+		return nil, newError(c.site, err, "解析最近公告失败")
+	}
+	if len(announcements.Announcements) == 0 {
+		return nil, nil
+	}
+	a := announcements.Announcements[0]
+	return &Notice{
+		Title:   a.Title,
+		Date:    siteadapt.GetTimeStamp(a.NewsTime),
+		Content: a.Body,
+	}, nil
+}
+
+func (c *gzClient) SignIn(ctx context.Context) (SignInResult, error) {
+	ubi, err := c.UserBasicInfo(WithoutCache(ctx))
+	// 重定向到登录页时返回 ErrNotLoggedIn，ubi.IsLogin 为 false
+	if err != nil && !errors.Is(err, ErrNotLoggedIn) {
+		return SignInResult{}, err
+	}
+	if !ubi.IsLogin {
+		return SignInResult{
+			Code:    SignInCodeNeedLogin,
+			Message: "未登录",
+		}, nil
+	}
+	return SignInResult{
+		Code:    SignInCodeSuccess,
+		Message: "模拟登录成功",
+	}, nil
+}
+
+func (c *gzClient) GetDownloadUrl(ctx context.Context, torrent SearchTorrent) (string, error) {
+	if len(torrent.Enclosure) > 0 {
+		return torrent.Enclosure, nil
+	}
+	index, err := c.index(ctx)
+	if err != nil {
+		return "", err
+	}
+	domain, err := SiteHelper.GetDomain(*c.site)
+	if err != nil {
+		return "", err
+	}
+	return gzDownloadUrl(domain, torrent.ID, index)
+}
+
+func (c *gzClient) Details(ctx context.Context, id string) (TorrentDetail, error) {
+	var detail gzTorrentDetail
+	err := gzAjax(ctx, c.site, requestIdGZTorrent, url.Values{
+		"action": {string(requestIdGZTorrent)},
+		"id":     {id},
+	}, &detail)
+	if err != nil {
+		var se *SiteError
+		// 种子不存在时返回 bad id parameter
+		if errors.As(err, &se) && strings.Contains(se.Err.Error(), "bad id") {
+			return TorrentDetail{Absent: true}, nil
+		}
+		return TorrentDetail{}, newError(c.site, err, "获取详情异常")
+	}
+	return TorrentDetail{
+		Free:      bool(detail.Torrent.FreeTorrent),
+		PeerCount: detail.Torrent.Seeders,
+	}, nil
+}
+
+// Rss 配置了 RssUrl 时拉取 RSS，否则使用 notifications 接口获取符合通知过滤器的新种子
+func (c *gzClient) Rss(ctx context.Context) ([]RssTorrent, error) {
+	if len(c.site.RssUrl) > 0 {
+		return c.NexusPHPClient.Rss(ctx)
+	}
+	index, err := c.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var notifications gzNotifications
+	err = gzAjax(ctx, c.site, requestIdGZNotifications, url.Values{
+		"action": {string(requestIdGZNotifications)},
+	}, &notifications)
+	if err != nil {
+		return nil, newError(c.site, err, "获取订阅通知异常")
+	}
+	domain, err := SiteHelper.GetDomain(*c.site)
+	if err != nil {
+		return nil, err
+	}
+	var torrents []RssTorrent
+	for _, n := range notifications.Results {
+		id := n.TorrentID.String()
+		link, err := JoinURL(domain, fmt.Sprintf("torrents.php?id=%s&torrentid=%s", n.GroupID, id))
+		if err != nil {
+			return nil, newError(c.site, err, "订阅通知拼接链接错误")
+		}
+		enclosure, err := gzDownloadUrl(domain, id, index)
+		if err != nil {
+			return nil, newError(c.site, err, "订阅通知拼接链接错误")
+		}
+		title := n.GroupName
+		if year := string(n.GroupYear); len(year) > 0 && year != "0" {
+			title = fmt.Sprintf("%s [%s]", title, year)
+		}
+		var edition []string
+		for _, s := range []string{n.RemasterTitle, n.Format, n.Encoding, n.Media} {
+			if len(s) > 0 {
+				edition = append(edition, s)
+			}
+		}
+		torrents = append(torrents, RssTorrent{
+			ID:          id,
+			Title:       title,
+			Enclosure:   enclosure,
+			Size:        gzInt(n.Size),
+			Description: strings.Join(edition, " / "),
+			Link:        link,
+			PubDate:     siteadapt.GetTimeStamp(n.NotificationTime),
+			Parsed:      parseRelease(title),
+		})
+	}
+	return torrents, nil
+}
+
+// index 获取下载种子需要的用户 id、authkey 和 passkey，成功后缓存在客户端，避免每次搜索都多请求一次 index
+func (c *gzClient) index(ctx context.Context) (gzIndex, error) {
+	c.mu.Lock()
+	cached := c.idIndex
+	c.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+	return c.fetchIndex(ctx)
+}
+
+// fetchIndex 请求 index 获取当前用户信息并更新缓存
+func (c *gzClient) fetchIndex(ctx context.Context) (gzIndex, error) {
+	var index gzIndex
+	err := gzAjax(ctx, c.site, requestIdGZIndex, url.Values{
+		"action": {string(requestIdGZIndex)},
+	}, &index)
+	if err != nil {
+		return index, newError(c.site, err, "解析基础信息失败")
+	}
+	if len(index.AuthKey) > 0 {
+		c.mu.Lock()
+		c.idIndex = &index
+		c.mu.Unlock()
+	}
+	return index, nil
+}
+
+// user 获取用户详情，未配置用户 id 时获取当前用户的 id
+func (c *gzClient) user(ctx context.Context) (gzUser, error) {
+	var user gzUser
+	userId := c.site.UserId
+	if len(userId) == 0 {
+		index, err := c.index(ctx)
+		if err != nil {
+			return user, err
+		}
+		userId = strconv.FormatInt(index.ID, 10)
+	}
+	err := gzAjax(ctx, c.site, requestIdGZUser, url.Values{
+		"action": {string(requestIdGZUser)},
+		"id":     {userId},
+	}, &user)
+	if err != nil {
+		return user, newError(c.site, err, "解析用户详情信息异常")
+	}
+	return user, nil
+}
+
+// gzAjax 请求 ajax.php 并解析 response 字段，status 不为 success 时返回 SiteError，只缓存 status 为 success 的响应
+func gzAjax[T any](ctx context.Context, site *Site, reqId requestId, params url.Values, output *T) error {
+	var r gzResponse[T]
+	rsp := requestSiteParams{
+		ctx:    ctx,
+		site:   site,
+		reqId:  reqId,
+		params: params,
+		check: func(data []byte) error {
+			r = gzResponse[T]{}
+			if err := json.Unmarshal(data, &r); err != nil {
+				return &SiteError{Site: site.Code, RequestId: string(reqId), Kind: ErrParse, Err: err}
+			}
+			if r.Status != "success" {
+				se := &SiteError{
+					Site:      site.Code,
+					RequestId: string(reqId),
+					Err:       fmt.Errorf("status: %s, error: %s", r.Status, r.Error),
+				}
+				if strings.Contains(r.Error, "credentials") || strings.Contains(r.Error, "login") {
+					se.Kind = ErrNotLoggedIn
+				}
+				return se
+			}
+			return nil
+		},
+	}
+	err := raw(rsp, func(siteadapt.RawResult) {})
+	if err != nil {
+		return err
+	}
+	*output = r.Response
+	return nil
+}
+
+// gzDownloadUrl 拼接种子下载地址
+func gzDownloadUrl(domain string, id string, index gzIndex) (string, error) {
+	return JoinURL(domain, fmt.Sprintf("torrents.php?action=download&id=%s&authkey=%s&torrent_pass=%s",
+		url.QueryEscape(id), url.QueryEscape(index.AuthKey), url.QueryEscape(index.PassKey)))
+}
@@ -0,0 +1,324 @@
+package btsite_test
+
+import (
+	"context"
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+// newFixtureClient 使用 configs/commons 下的公共配置和 testdata 下的响应创建客户端，
+// route 根据请求返回 testdata/<dir> 下的文件名，opts 可修改站点配置
+func newFixtureClient(t *testing.T, schema string, dir string, route func(r *http.Request) string, opts ...func(site *btsite.Site)) (btsite.ClientContext, *httptest.Server) {
+	t.Helper()
+	common, err := os.ReadFile(filepath.Join("configs", "commons", schema+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := btsite.LoadConfig(fstest.MapFS{
+		"commons/" + schema + ".json": {Data: common},
+		"sites/fixture.json": {Data: []byte(`{"id": "fixture", "name": "Fixture", "schema": "` + schema + `",
+			"category": {"movie": [{"id": "1", "cat": "Movies"}], "tv": [{"id": "2", "cat": "TV"}]}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := route(r)
+		if name == "" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", dir, name))
+	}))
+	t.Cleanup(srv.Close)
+	site := &btsite.Site{
+		Code:   "fixture",
+		Name:   "Fixture",
+		Domain: srv.URL + "/",
+		Cookie: "session=fixture",
+	}
+	for _, opt := range opts {
+		opt(site)
+	}
+	c, err := btsite.NewRegistry(conf).NewClientContext(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, srv
+}
+
+func newGazelleClient(t *testing.T) (btsite.ClientContext, *httptest.Server) {
+	return newFixtureClient(t, "Gazelle", "gazelle", func(r *http.Request) string {
+		q := r.URL.Query()
+		switch action := q.Get("action"); action {
+		case "inbox":
+			if q.Get("type") == "viewconv" {
+				return "viewconv.json"
+			}
+			return "inbox.json"
+		case "torrent":
+			if q.Get("id") != "100" {
+				return "torrent_missing.json"
+			}
+			return "torrent.json"
+		case "index", "browse", "user", "announcements", "notifications":
+			return action + ".json"
+		}
+		return ""
+	})
+}
+
+func TestGazelleUserBasicInfo(t *testing.T) {
+	c, _ := newGazelleClient(t)
+	info, err := c.UserBasicInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := btsite.UserBasicInfo{
+		IsLogin:            true,
+		ID:                 "1024",
+		Name:               "tester",
+		UnreadMessageCount: 1,
+		Ratio:              2,
+		Uploaded:           10737418240,
+		Downloaded:         5368709120,
+		Bonus:              1500,
+	}
+	if info != want {
+		t.Errorf("UserBasicInfo() = %+v, want %+v", info, want)
+	}
+}
+
+func TestGazelleSearch(t *testing.T) {
+	c, srv := newGazelleClient(t)
+	torrents, err := c.Search(context.Background(), btsite.SearchParams{Keyword: "album"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 3 {
+		t.Fatalf("Search() = %d torrents, want 3", len(torrents))
+	}
+	flac := torrents[0]
+	if flac.ID != "100" || flac.Title != "Artist - Album Name [2020]" || flac.Description != "FLAC / Lossless / CD" ||
+		flac.Size != 314572800 || flac.Seeders != 8 || flac.Grabs != 12 || flac.DownloadVolumeFactor != 0 ||
+		flac.UploadVolumeFactor != 1 || flac.Category != "Album" {
+		t.Errorf("Search()[0] = %+v", flac)
+	}
+	if want := srv.URL + "/torrents.php?action=download&id=100&authkey=auth123&torrent_pass=pass456"; flac.Enclosure != want {
+		t.Errorf("Search()[0].Enclosure = %s, want %s", flac.Enclosure, want)
+	}
+	if want := srv.URL + "/torrents.php?id=10&torrentid=100"; flac.PageURL != want {
+		t.Errorf("Search()[0].PageURL = %s, want %s", flac.PageURL, want)
+	}
+	neutral := torrents[1]
+	if neutral.Size != 104857600 || neutral.DownloadVolumeFactor != 0 || neutral.UploadVolumeFactor != 0 {
+		t.Errorf("Search()[1] = %+v", neutral)
+	}
+	ebook := torrents[2]
+	if ebook.ID != "200" || ebook.Title != "Ebook Name" || ebook.Category != "E-Books" || ebook.Size != 1048576 {
+		t.Errorf("Search()[2] = %+v", ebook)
+	}
+}
+
+func TestGazelleUnreadMessages(t *testing.T) {
+	c, _ := newGazelleClient(t)
+	messages, err := c.UnreadMessages(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ID != "7" || messages[0].Head != "Welcome" ||
+		messages[0].Content != "Welcome to the site" {
+		t.Errorf("UnreadMessages() = %+v", messages)
+	}
+}
+
+func TestGazelleSeedingStatistics(t *testing.T) {
+	c, _ := newGazelleClient(t)
+	ss, err := c.SeedingStatistics(context.Background())
+	if err != nil || ss.Count != 42 {
+		t.Errorf("SeedingStatistics() = %+v, %v, want count 42", ss, err)
+	}
+}
+
+func TestGazelleLatestNotice(t *testing.T) {
+	c, _ := newGazelleClient(t)
+	notice, err := c.LatestNotice(context.Background())
+	if err != nil || notice == nil || notice.Title != "Site maintenance" || notice.Content != "Maintenance on Sunday" {
+		t.Errorf("LatestNotice() = %+v, %v", notice, err)
+	}
+}
+
+func TestGazelleDetails(t *testing.T) {
+	c, _ := newGazelleClient(t)
+	detail, err := c.Details(context.Background(), "100")
+	if err != nil || !detail.Free || detail.PeerCount != 8 || detail.Absent {
+		t.Errorf("Details(100) = %+v, %v", detail, err)
+	}
+	detail, err = c.Details(context.Background(), "999")
+	if err != nil || !detail.Absent {
+		t.Errorf("Details(999) = %+v, %v, want absent", detail, err)
+	}
+}
+
+func TestGazelleSignIn(t *testing.T) {
+	c, _ := newGazelleClient(t)
+	r, err := c.SignIn(context.Background())
+	if err != nil || r.Code != btsite.SignInCodeSuccess {
+		t.Errorf("SignIn() = %+v, %v", r, err)
+	}
+}
+
+func TestGazelleNotLoggedIn(t *testing.T) {
+	c, _ := newFixtureClient(t, "Gazelle", "gazelle", func(r *http.Request) string {
+		return "bad_credentials.json"
+	})
+	_, err := c.UserBasicInfo(context.Background())
+	if !errors.Is(err, btsite.ErrNotLoggedIn) {
+		t.Errorf("UserBasicInfo() err = %v, want ErrNotLoggedIn", err)
+	}
+}
+
+func TestGazelleIndexCached(t *testing.T) {
+	var indexHits atomic.Int32
+	var browseQuery atomic.Value
+	c, _ := newFixtureClient(t, "Gazelle", "gazelle", func(r *http.Request) string {
+		q := r.URL.Query()
+		switch action := q.Get("action"); action {
+		case "index":
+			indexHits.Add(1)
+			return "index.json"
+		case "browse":
+			browseQuery.Store(q)
+			return "browse.json"
+		}
+		return ""
+	})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Search(ctx, btsite.SearchParams{Keyword: "album", MediaType: btsite.Movie}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := indexHits.Load(); n != 1 {
+		t.Errorf("index requested %d times by Search, want 1", n)
+	}
+	if q := browseQuery.Load().(url.Values); q.Get("filter_cat[1]") != "1" || q.Has("filter_cat[2]") {
+		t.Errorf("browse query = %v, want filter_cat[1] only", q)
+	}
+	// 基础信息包含统计数据，每次都重新请求
+	if _, err := c.UserBasicInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := indexHits.Load(); n != 2 {
+		t.Errorf("index requested %d times, want 2", n)
+	}
+}
+
+func TestGazelleSearchParams(t *testing.T) {
+	var browseQuery url.Values
+	c, _ := newFixtureClient(t, "Gazelle", "gazelle", func(r *http.Request) string {
+		q := r.URL.Query()
+		switch q.Get("action") {
+		case "index":
+			return "index.json"
+		case "browse":
+			browseQuery = q
+			return "browse.json"
+		}
+		return ""
+	})
+	ctx := context.Background()
+	torrents, err := c.Search(ctx, btsite.SearchParams{
+		Keyword:    "album",
+		MediaType:  btsite.Movie,
+		Categories: []string{"3"},
+		IMDbID:     "tt0133093",
+		SortBy:     btsite.SortBySeeders,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if browseQuery.Get("filter_cat[3]") != "1" || browseQuery.Has("filter_cat[1]") ||
+		browseQuery.Get("order_by") != "seeders" || browseQuery.Get("order_way") != "desc" {
+		t.Errorf("browse query = %v", browseQuery)
+	}
+	// 不支持按 IMDb 编号搜索，在本地过滤，搜索结果没有 IMDb 编号
+	if len(torrents) != 0 {
+		t.Errorf("got %d torrents, want 0 after IMDb filter", len(torrents))
+	}
+	if _, err := c.Search(ctx, btsite.SearchParams{IMDbID: "tt0133093"}); !errors.Is(err, btsite.ErrUnsupportedSearch) {
+		t.Errorf("Search(imdb) err = %v, want ErrUnsupportedSearch", err)
+	}
+}
+
+func TestGazelleRssNotifications(t *testing.T) {
+	c, srv := newGazelleClient(t)
+	torrents, err := c.Rss(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 1 {
+		t.Fatalf("Rss() = %d torrents, want 1", len(torrents))
+	}
+	r := torrents[0]
+	if r.ID != "300" || r.Title != "New Album [2024]" || r.Size != 209715200 || r.Description != "FLAC / 24bit Lossless / WEB" {
+		t.Errorf("Rss()[0] = %+v", r)
+	}
+	if want := srv.URL + "/torrents.php?action=download&id=300&authkey=auth123&torrent_pass=pass456"; r.Enclosure != want {
+		t.Errorf("Rss()[0].Enclosure = %s, want %s", r.Enclosure, want)
+	}
+	if want := srv.URL + "/torrents.php?id=30&torrentid=300"; r.Link != want {
+		t.Errorf("Rss()[0].Link = %s, want %s", r.Link, want)
+	}
+}
+
+func TestGazelleFailureNotCached(t *testing.T) {
+	common, err := os.ReadFile(filepath.Join("configs", "commons", "Gazelle.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := btsite.LoadConfig(fstest.MapFS{
+		"commons/Gazelle.json": {Data: common},
+		"sites/fixture.json": {Data: []byte(`{"id": "fixture", "name": "Fixture", "schema": "Gazelle",
+			"cache": {"announcements": 60}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "announcements.json"
+		if hits.Add(1) == 1 {
+			name = "bad_credentials.json"
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", "gazelle", name))
+	}))
+	defer srv.Close()
+	r := btsite.NewRegistry(conf)
+	r.SetCache(btsite.NewLRUCache(10))
+	c, err := r.NewClientContext(&btsite.Site{Code: "fixture", Name: "Fixture", Domain: srv.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.LatestNotice(ctx); !errors.Is(err, btsite.ErrNotLoggedIn) {
+		t.Fatalf("LatestNotice() err = %v, want ErrNotLoggedIn", err)
+	}
+	// status 为 failure 的响应不缓存
+	for i := 0; i < 2; i++ {
+		if notice, err := c.LatestNotice(ctx); err != nil || notice == nil {
+			t.Fatalf("LatestNotice() = %v, %v", notice, err)
+		}
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("hits = %d, want 2", got)
+	}
+}
@@ -0,0 +1,117 @@
+package btsite
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// InfoHashFromMagnet 从磁力链接的 xt=urn:btih: 获取 v1 info-hash，支持十六进制和 base32，
+// 统一返回 40 位十六进制小写，不是磁力链接或没有 btih 时返回空
+func InfoHashFromMagnet(magnet string) string {
+	if !strings.HasPrefix(strings.ToLower(magnet), "magnet:?") {
+		return ""
+	}
+	values, err := url.ParseQuery(magnet[len("magnet:?"):])
+	if err != nil {
+		return ""
+	}
+	for _, xt := range values["xt"] {
+		if len(xt) > len("urn:btih:") && strings.EqualFold(xt[:len("urn:btih:")], "urn:btih:") {
+			if hash := normalizeInfoHash(xt[len("urn:btih:"):]); hash != "" {
+				return hash
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeInfoHash 将十六进制或 base32 的 v1 info-hash 转为 40 位十六进制小写，格式错误时返回空
+func normalizeInfoHash(s string) string {
+	s = strings.TrimSpace(s)
+	switch len(s) {
+	case 40:
+		if _, err := hex.DecodeString(s); err == nil {
+			return strings.ToLower(s)
+		}
+	case 32:
+		if b, err := base32.StdEncoding.DecodeString(strings.ToUpper(s)); err == nil {
+			return hex.EncodeToString(b)
+		}
+	}
+	return ""
+}
+
+// MagnetURI 生成磁力链接，name 为空时不设置 dn，size <= 0 时不设置 xl
+func MagnetURI(infoHash string, name string, size int64, trackers ...string) string {
+	var sb strings.Builder
+	sb.WriteString("magnet:?xt=urn:btih:" + strings.ToLower(infoHash))
+	if name != "" {
+		sb.WriteString("&dn=" + url.QueryEscape(name))
+	}
+	if size > 0 {
+		sb.WriteString("&xl=" + strconv.FormatInt(size, 10))
+	}
+	for _, tracker := range trackers {
+		sb.WriteString("&tr=" + url.QueryEscape(tracker))
+	}
+	return sb.String()
+}
+
+// torrentInfoHash 搜索结果的 info-hash，优先使用 infohash 字段，其次为磁力链接
+func torrentInfoHash(infoHash string, enclosure string) string {
+	if hash := normalizeInfoHash(infoHash); hash != "" {
+		return hash
+	}
+	return InfoHashFromMagnet(enclosure)
+}
+
+// ResolveInfoHash 获取种子的 v1 info-hash，依次使用 torrent.InfoHash、磁力链接，都没有时通过 c 下载种子文件计算。
+// 纯 v2 种子没有 v1 info-hash，返回异常
+func ResolveInfoHash(ctx context.Context, c ClientContext, torrent SearchTorrent) (string, error) {
+	if hash := torrentInfoHash(torrent.InfoHash, torrent.Enclosure); hash != "" {
+		return hash, nil
+	}
+	tf, err := DownloadTorrent(ctx, c, torrent)
+	if err != nil {
+		return "", err
+	}
+	if tf.InfoHash == "" {
+		return "", fmt.Errorf("种子(%s)为 v2 种子，没有 v1 info-hash", torrent.ID)
+	}
+	return tf.InfoHash, nil
+}
+
+// GetAnnounceUrl 站点的 tracker 地址，优先使用 Site.AnnounceUrl，其次为站点配置的 announce
+func (sh *helper) GetAnnounceUrl(site Site) (string, error) {
+	if len(site.AnnounceUrl) > 0 {
+		return site.AnnounceUrl, nil
+	}
+	sc, err := siteConfig(&site)
+	if err != nil {
+		return "", err
+	}
+	return sc.Announce, nil
+}
+
+// MagnetURI 使用站点的 tracker 地址生成种子的磁力链接，torrent 没有 info-hash 时返回异常，
+// 需要下载种子文件获取时先调用 ResolveInfoHash
+func (sh *helper) MagnetURI(site Site, torrent SearchTorrent) (string, error) {
+	hash := torrentInfoHash(torrent.InfoHash, torrent.Enclosure)
+	if hash == "" {
+		return "", fmt.Errorf("站点(%s)种子(%s)没有 info-hash", site.Name, torrent.ID)
+	}
+	announce, err := sh.GetAnnounceUrl(site)
+	if err != nil {
+		return "", err
+	}
+	var trackers []string
+	if announce != "" {
+		trackers = append(trackers, announce)
+	}
+	return MagnetURI(hash, torrent.Title, torrent.Size, trackers...), nil
+}
@@ -0,0 +1,65 @@
+package btsite_test
+
+import (
+	"context"
+	"github.com/heibizi/go-btsite"
+	"net/http"
+	"testing"
+)
+
+func TestInfoHashFromMagnet(t *testing.T) {
+	tests := []struct {
+		magnet string
+		want   string
+	}{
+		{"magnet:?xt=urn:btih:E2BF155B08156D14FA8014B8556BCDC7C7B578A0&dn=x", "e2bf155b08156d14fa8014b8556bcdc7c7b578a0"},
+		{"magnet:?dn=x&xt=urn:btmh:1220abcd&xt=urn:btih:4k7rkwyicvwrj6uacs4fk26ny7d3k6fa", "e2bf155b08156d14fa8014b8556bcdc7c7b578a0"},
+		{"magnet:?xt=urn:btih:invalid", ""},
+		{"https://example.com/download.php?id=1", ""},
+	}
+	for _, tt := range tests {
+		if got := btsite.InfoHashFromMagnet(tt.magnet); got != tt.want {
+			t.Errorf("InfoHashFromMagnet(%q) = %q, want %q", tt.magnet, got, tt.want)
+		}
+	}
+}
+
+func TestMagnetURI(t *testing.T) {
+	got := btsite.MagnetURI("E2BF155B08156D14FA8014B8556BCDC7C7B578A0", "The Matrix", 1024, "https://tracker.example/announce.php?passkey=abc")
+	want := "magnet:?xt=urn:btih:e2bf155b08156d14fa8014b8556bcdc7c7b578a0&dn=The+Matrix&xl=1024" +
+		"&tr=https%3A%2F%2Ftracker.example%2Fannounce.php%3Fpasskey%3Dabc"
+	if got != want {
+		t.Errorf("MagnetURI() = %q, want %q", got, want)
+	}
+	site := btsite.Site{Code: "fixture", Name: "Fixture", AnnounceUrl: "https://tracker.example/announce"}
+	got, err := btsite.SiteHelper.MagnetURI(site, btsite.SearchTorrent{ID: "1", Title: "x", InfoHash: "e2bf155b08156d14fa8014b8556bcdc7c7b578a0"})
+	if err != nil || got != "magnet:?xt=urn:btih:e2bf155b08156d14fa8014b8556bcdc7c7b578a0&dn=x&tr=https%3A%2F%2Ftracker.example%2Fannounce" {
+		t.Errorf("SiteHelper.MagnetURI() = %q, %v", got, err)
+	}
+	if _, err := btsite.SiteHelper.MagnetURI(site, btsite.SearchTorrent{ID: "1"}); err == nil {
+		t.Error("SiteHelper.MagnetURI() without info-hash err = nil, want error")
+	}
+}
+
+func TestResolveInfoHash(t *testing.T) {
+	var downloads int
+	c, _ := newFixtureClient(t, "Gazelle", "torrent", func(r *http.Request) string {
+		downloads++
+		return "v1.torrent"
+	})
+	ctx := context.Background()
+	magnet := btsite.SearchTorrent{Enclosure: "magnet:?xt=urn:btih:79b95b33d0babe4e7450e99ae7d088f67c1d8a85"}
+	if got, err := btsite.ResolveInfoHash(ctx, c, magnet); err != nil || got != "79b95b33d0babe4e7450e99ae7d088f67c1d8a85" {
+		t.Errorf("ResolveInfoHash(magnet) = %q, %v", got, err)
+	}
+	if downloads != 0 {
+		t.Errorf("ResolveInfoHash(magnet) downloads = %d, want 0", downloads)
+	}
+	download := btsite.SearchTorrent{ID: "1", Enclosure: "torrents.php?action=download&id=1"}
+	if got, err := btsite.ResolveInfoHash(ctx, c, download); err != nil || got != "e2bf155b08156d14fa8014b8556bcdc7c7b578a0" {
+		t.Errorf("ResolveInfoHash(download) = %q, %v", got, err)
+	}
+	if downloads != 1 {
+		t.Errorf("ResolveInfoHash(download) downloads = %d, want 1", downloads)
+	}
+}
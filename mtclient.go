@@ -1,16 +1,18 @@
 package btsite
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/heibizi/go-siteadapt"
 	"strings"
-	"time"
 )
 
 type (
 	// mtClient 馒头客户端
 	mtClient struct {
 		site *Site
-		*npClient
+		*NexusPHPClient
 	}
 	mtMyPeerStatus struct {
 		Leecher int `mapstructure:"leecher"`
@@ -49,12 +51,33 @@ const (
 	requestIdMTGenDLToken         requestId = "gen_dl_token"
 )
 
-func (c *mtClient) UserBasicInfo() (UserBasicInfo, error) {
-	mp, err := c.memberProfile()
+// 馒头搜索的 discount 参数，不支持 30% 下载
+var mtDiscounts = map[Promotion]string{
+	PromotionNormal:  "NORMAL",
+	PromotionFree:    "FREE",
+	Promotion2X:      "_2X",
+	Promotion2XFree:  "_2X_FREE",
+	PromotionHalf:    "PERCENT_50",
+	Promotion2XHalf:  "_2X_PERCENT_50",
+	PromotionSeventy: "PERCENT_70",
+}
+
+// 馒头搜索的 sortField 参数
+var mtSortFields = map[SortField]string{
+	SortByTime:     "createdDate",
+	SortBySize:     "size",
+	SortBySeeders:  "seeders",
+	SortByLeechers: "leechers",
+	SortByGrabs:    "timesCompleted",
+	SortByTitle:    "name",
+}
+
+func (c *mtClient) UserBasicInfo(ctx context.Context) (UserBasicInfo, error) {
+	mp, err := c.memberProfile(ctx)
 	if err != nil {
 		return UserBasicInfo{}, err
 	}
-	mns, err := c.msgNotifyStatistic()
+	mns, err := c.msgNotifyStatistic(ctx)
 	if err != nil {
 		return UserBasicInfo{}, err
 	}
@@ -70,13 +93,13 @@ func (c *mtClient) UserBasicInfo() (UserBasicInfo, error) {
 	}, nil
 }
 
-func (c *mtClient) UserDetails() (UserDetails, error) {
-	mp, err := c.memberProfile()
+func (c *mtClient) UserDetails(ctx context.Context) (UserDetails, error) {
+	mp, err := c.memberProfile(ctx)
 	if err != nil {
 		return UserDetails{}, err
 	}
 	level := ""
-	srl, err := c.sysRoleList()
+	srl, err := c.sysRoleList(ctx)
 	if err != nil {
 		return UserDetails{}, err
 	}
@@ -94,27 +117,13 @@ func (c *mtClient) UserDetails() (UserDetails, error) {
 	}, nil
 }
 
-func (c *mtClient) Search(searchParams SearchParams) ([]SearchTorrent, error) {
-	mode := "movie"
-	if searchParams.MediaType == Tv {
-		mode = "tvshow"
-	} else if searchParams.MediaType == Anime {
-		mode = "normal"
-	}
-	body := map[string]any{
-		"mode":    mode,
-		"visible": 1,
-		// 馒头从 1 开始
-		"pageNumber": searchParams.Page + 1,
-		"pageSize":   100,
-	}
-	if len(searchParams.Keyword) > 0 {
-		body["keyword"] = searchParams.Keyword
-	}
+func (c *mtClient) Search(ctx context.Context, searchParams SearchParams) ([]SearchTorrent, error) {
+	body := mtSearchBody(searchParams)
 	site := c.site
 	var torrents []torrent
 	domain := ""
 	err := list(requestSiteParams{
+		ctx:   ctx,
 		site:  site,
 		reqId: requestIdSearch,
 		body:  body,
@@ -124,6 +133,11 @@ func (c *mtClient) Search(searchParams SearchParams) ([]SearchTorrent, error) {
 	if err != nil {
 		return nil, newError(site, err, "搜索异常")
 	}
+	sc, err := siteConfig(site)
+	if err != nil {
+		return nil, newError(site, err, "未获取到站点配置")
+	}
+	loc := siteLocation(sc)
 	var searchTorrents []SearchTorrent
 	for _, torrent := range torrents {
 		pageUrl, err := JoinURL(domain, torrent.Details)
@@ -148,21 +162,81 @@ func (c *mtClient) Search(searchParams SearchParams) ([]SearchTorrent, error) {
 			Size:                 torrent.Size,
 			DownloadVolumeFactor: torrent.DownloadVolumeFactor,
 			UploadVolumeFactor:   torrent.UploadVolumeFactor,
-			PubDate:              torrent.DateAdded,
-			DateElapsed:          torrent.DateElapsed,
+			PubDate:              parseTime(torrent.DateAdded, loc),
+			PubDateRaw:           torrent.DateAdded,
+			DateElapsed:          parseDuration(torrent.DateElapsed),
+			DateElapsedRaw:       torrent.DateElapsed,
 			HrDays:               torrent.HrDays,
 			HitAndRun:            torrent.HrDays > 0,
 			Labels:               labels,
+			IMDbID:               normalizeIMDbID(torrent.IMDbID),
+			DoubanID:             normalizeDoubanID(torrent.DoubanID),
+			PosterURL:            joinURLs(domain, torrent.Poster),
+			Subtitle:             torrent.Subtitle,
+			PromotionExpiresAt:   parseTime(torrent.FreeDeadline, loc),
 		}
 		searchTorrents = append(searchTorrents, search)
 	}
-	return searchTorrents, nil
+	_, promotion := mtDiscounts[searchParams.Promotion]
+	// 依次按关键字、IMDb 编号、豆瓣编号搜索，未使用的编号在本地过滤
+	return searchResult(ctx, searchParams, searchTorrents, localFilter{
+		promotion: !promotion,
+		imdb:      len(searchParams.Keyword) > 0,
+		douban:    len(searchParams.Keyword) > 0 || len(searchParams.IMDbID) > 0,
+	}), nil
+}
+
+// mtSearchBody 馒头的搜索请求体
+func mtSearchBody(searchParams SearchParams) map[string]any {
+	mode := "movie"
+	if searchParams.MediaType == Tv {
+		mode = "tvshow"
+	} else if searchParams.MediaType == Anime {
+		mode = "normal"
+	}
+	body := map[string]any{
+		"mode":    mode,
+		"visible": 1,
+		// 馒头从 1 开始
+		"pageNumber": searchParams.Page + 1,
+		"pageSize":   100,
+	}
+	if len(searchParams.Keyword) > 0 {
+		body["keyword"] = searchParams.Keyword
+	} else if len(searchParams.IMDbID) > 0 {
+		body["imdb"] = searchParams.IMDbID
+	} else if len(searchParams.DoubanID) > 0 {
+		body["douban"] = searchParams.DoubanID
+	}
+	if len(searchParams.Categories) > 0 {
+		body["categories"] = searchParams.Categories
+	}
+	if discount, ok := mtDiscounts[searchParams.Promotion]; ok {
+		body["discount"] = discount
+	}
+	if searchParams.IncludeDead {
+		// visible 为 1 时只搜索活种
+		delete(body, "visible")
+	}
+	if sortField, ok := mtSortFields[searchParams.SortBy]; ok {
+		body["sortField"] = sortField
+		body["sortDirection"] = "DESC"
+		if searchParams.SortOrder == SortAsc {
+			body["sortDirection"] = "ASC"
+		}
+	}
+	return body
 }
 
-func (c *mtClient) SeedingStatistics() (SeedingStatistics, error) {
+func (c *mtClient) SeedingStatistics(ctx context.Context) (SeedingStatistics, error) {
 	seeding := SeedingStatistics{}
 	for pageNumber := 1; ; pageNumber++ {
-		tl, err := c.userTorrentList(pageNumber)
+		if pageNumber > 1 {
+			if err := waitNextPage(ctx, c.site); err != nil {
+				return seeding, err
+			}
+		}
+		tl, err := c.userTorrentList(ctx, pageNumber)
 		if err != nil {
 			return seeding, err
 		}
@@ -173,19 +247,19 @@ func (c *mtClient) SeedingStatistics() (SeedingStatistics, error) {
 			seeding.Count = seeding.Count + 1
 			seeding.Size = seeding.Size + ut.Size
 		}
-		time.Sleep(500 * time.Millisecond)
 	}
 	return seeding, nil
 }
 
-func (c *mtClient) MyHr() ([]HrTorrent, error) {
+func (c *mtClient) MyHr(ctx context.Context) ([]HrTorrent, error) {
 	// 无 hr
 	return nil, nil
 }
 
-func (c *mtClient) SignIn() (SignInResult, error) {
-	ubi, err := c.UserBasicInfo()
-	if err != nil {
+func (c *mtClient) SignIn(ctx context.Context) (SignInResult, error) {
+	ubi, err := c.UserBasicInfo(WithoutCache(ctx))
+	// 重定向到登录页时返回 ErrNotLoggedIn，ubi.IsLogin 为 false
+	if err != nil && !errors.Is(err, ErrNotLoggedIn) {
 		return SignInResult{}, err
 	}
 	if !ubi.IsLogin {
@@ -206,10 +280,11 @@ func (c *mtClient) SignIn() (SignInResult, error) {
 	}, nil
 }
 
-func (c *mtClient) UnreadMessages(detail bool) ([]Message, error) {
+func (c *mtClient) UnreadMessages(ctx context.Context, detail bool) ([]Message, error) {
 	var o []Message
 	fromDataEnv := map[string]string{"pageNumber": "1"}
 	err := list(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdUnreadMessages,
 		env:   fromDataEnv,
@@ -222,7 +297,7 @@ func (c *mtClient) UnreadMessages(detail bool) ([]Message, error) {
 		for _, message := range o {
 			ids = append(ids, message.ID)
 		}
-		err = c.markAsRead(ids)
+		err = c.markAsRead(ctx, ids)
 		if err != nil {
 		}
 		return nil, err
@@ -230,9 +305,10 @@ func (c *mtClient) UnreadMessages(detail bool) ([]Message, error) {
 	return o, nil
 }
 
-func (c *mtClient) myPeerStatus() (mtMyPeerStatus, error) {
+func (c *mtClient) myPeerStatus(ctx context.Context) (mtMyPeerStatus, error) {
 	o := mtMyPeerStatus{}
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMTMyPeerStatus,
 	}, &o, nil)
@@ -242,9 +318,10 @@ func (c *mtClient) myPeerStatus() (mtMyPeerStatus, error) {
 	return o, nil
 }
 
-func (c *mtClient) msgNotifyStatistic() (mtMsgNotifyStatistic, error) {
+func (c *mtClient) msgNotifyStatistic(ctx context.Context) (mtMsgNotifyStatistic, error) {
 	o := mtMsgNotifyStatistic{}
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMTMsgNotifyStatistic,
 	}, &o, nil)
@@ -255,9 +332,10 @@ func (c *mtClient) msgNotifyStatistic() (mtMsgNotifyStatistic, error) {
 }
 
 // memberProfile 获取个人资料
-func (c *mtClient) memberProfile() (mtProfile, error) {
+func (c *mtClient) memberProfile(ctx context.Context) (mtProfile, error) {
 	o := mtProfile{}
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMTProfile,
 	}, &o, nil)
@@ -267,7 +345,7 @@ func (c *mtClient) memberProfile() (mtProfile, error) {
 	return o, nil
 }
 
-func (c *mtClient) userTorrentList(pageNumber int) ([]mtUserTorrent, error) {
+func (c *mtClient) userTorrentList(ctx context.Context, pageNumber int) ([]mtUserTorrent, error) {
 	var o []mtUserTorrent
 	var body = make(map[string]any)
 	body["userid"] = c.site.UserId
@@ -275,6 +353,7 @@ func (c *mtClient) userTorrentList(pageNumber int) ([]mtUserTorrent, error) {
 	body["pageNumber"] = pageNumber
 	body["pageSize"] = 100
 	err := list(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMTUserTorrentList,
 		body:  body,
@@ -285,9 +364,10 @@ func (c *mtClient) userTorrentList(pageNumber int) ([]mtUserTorrent, error) {
 	return o, nil
 }
 
-func (c *mtClient) sysRoleList() ([]mtSysRole, error) {
+func (c *mtClient) sysRoleList(ctx context.Context) ([]mtSysRole, error) {
 	var o []mtSysRole
 	err := list(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMTSysRoleList,
 	}, &o, nil)
@@ -297,10 +377,23 @@ func (c *mtClient) sysRoleList() ([]mtSysRole, error) {
 	return o, nil
 }
 
-func (c *mtClient) genDlToken(torrent SearchTorrent) (string, error) {
+// GetDownloadUrl 搜索结果没有下载地址时，通过 gen_dl_token 接口获取带令牌的下载地址
+func (c *mtClient) GetDownloadUrl(ctx context.Context, torrent SearchTorrent) (string, error) {
+	if len(torrent.Enclosure) > 0 || len(torrent.ID) == 0 {
+		return torrent.Enclosure, nil
+	}
+	downloadUrl, err := c.genDlToken(ctx, torrent)
+	if err != nil {
+		return "", newError(c.site, err, "获取下载地址异常")
+	}
+	return downloadUrl, nil
+}
+
+func (c *mtClient) genDlToken(ctx context.Context, torrent SearchTorrent) (string, error) {
 	formDataEnv := map[string]string{"id": torrent.ID}
 	m := make(map[string]any)
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMTGenDLToken,
 		env:   formDataEnv,
@@ -308,13 +401,18 @@ func (c *mtClient) genDlToken(torrent SearchTorrent) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return m["url"].(string), nil
+	downloadUrl, _ := m["url"].(string)
+	if len(downloadUrl) == 0 {
+		return "", fmt.Errorf("%w: gen_dl_token 没有返回下载地址", ErrParse)
+	}
+	return downloadUrl, nil
 }
 
 // markAsRead 未读消息设为已读
-func (c *mtClient) markAsRead(ids []string) error {
+func (c *mtClient) markAsRead(ctx context.Context, ids []string) error {
 	r := markAsReadResult{}
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMarkAsRead,
 		env:   map[string]string{"ids": strings.Join(ids, ",")},
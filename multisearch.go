@@ -0,0 +1,103 @@
+package btsite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultMultiSearchConcurrency = 5
+
+type (
+	// MultiSearchOptions 多站点聚合搜索参数
+	MultiSearchOptions struct {
+		Concurrency int           // 最大并发站点数，<= 0 时默认为 5
+		Timeout     time.Duration // 单个站点的超时时间，<= 0 时不限制
+	}
+	// SiteSearchTorrent 带来源站点的搜索种子
+	SiteSearchTorrent struct {
+		SiteCode string // 站点唯一标识
+		SearchTorrent
+	}
+	// SiteSearchStatus 单个站点的搜索结果
+	SiteSearchStatus struct {
+		SiteCode string        // 站点唯一标识
+		Count    int           // 种子数量
+		Latency  time.Duration // 耗时
+		Err      error         // 搜索异常，为空时表示搜索成功
+	}
+	// MultiSearchResult 多站点聚合搜索结果
+	MultiSearchResult struct {
+		Torrents []SiteSearchTorrent // 所有站点的种子，按 sites 的顺序排列
+		Sites    []SiteSearchStatus  // 每个站点的搜索结果，与 sites 一一对应
+	}
+)
+
+// errNilSite 多站点搜索时站点为空
+var errNilSite = errors.New("站点为空")
+
+// MultiSearch 使用默认注册表并发搜索多个站点，见 Registry.MultiSearch
+func MultiSearch(ctx context.Context, sites []*Site, searchParams SearchParams, opts MultiSearchOptions) MultiSearchResult {
+	return defaultRegistry.MultiSearch(ctx, sites, searchParams, opts)
+}
+
+// MultiSearch 并发搜索多个站点，单个站点失败不影响其他站点，失败原因见 MultiSearchResult.Sites，
+// sites 中为 nil 的站点不搜索，对应的 SiteSearchStatus.Err 不为空
+func (r *Registry) MultiSearch(ctx context.Context, sites []*Site, searchParams SearchParams, opts MultiSearchOptions) MultiSearchResult {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultMultiSearchConcurrency
+	}
+	statuses := make([]SiteSearchStatus, len(sites))
+	torrents := make([][]SearchTorrent, len(sites))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		wg.Add(1)
+		go func(i int, site *Site) {
+			defer wg.Done()
+			status := &statuses[i]
+			if site == nil {
+				status.Err = errNilSite
+				return
+			}
+			status.SiteCode = site.Code
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				status.Err = ctx.Err()
+				return
+			}
+			start := time.Now()
+			torrents[i], status.Err = r.searchSite(ctx, site, searchParams, opts.Timeout)
+			status.Latency = time.Since(start)
+			status.Count = len(torrents[i])
+		}(i, site)
+	}
+	wg.Wait()
+	result := MultiSearchResult{Sites: statuses}
+	for i := range sites {
+		for _, torrent := range torrents[i] {
+			result.Torrents = append(result.Torrents, SiteSearchTorrent{
+				SiteCode:      statuses[i].SiteCode,
+				SearchTorrent: torrent,
+			})
+		}
+	}
+	return result
+}
+
+func (r *Registry) searchSite(ctx context.Context, site *Site, searchParams SearchParams, timeout time.Duration) ([]SearchTorrent, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	c, err := r.NewClientContext(site)
+	if err != nil {
+		return nil, newError(site, err, "创建客户端失败")
+	}
+	return c.Search(ctx, searchParams)
+}
@@ -0,0 +1,91 @@
+package btsite_test
+
+import (
+	"context"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-siteadapt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultiSearchReportsSiteErrors(t *testing.T) {
+	sites := []*btsite.Site{
+		{Code: "go-btsite-missing-1", Name: "missing-1"},
+		{Code: "go-btsite-missing-2", Name: "missing-2"},
+	}
+	r := btsite.MultiSearch(context.Background(), sites, btsite.SearchParams{Keyword: "test"}, btsite.MultiSearchOptions{Concurrency: 1})
+	if len(r.Sites) != len(sites) {
+		t.Fatalf("sites = %d, want %d", len(r.Sites), len(sites))
+	}
+	for i, status := range r.Sites {
+		if status.SiteCode != sites[i].Code {
+			t.Errorf("sites[%d].SiteCode = %s, want %s", i, status.SiteCode, sites[i].Code)
+		}
+		if status.Err == nil {
+			t.Errorf("sites[%d].Err = nil, want error", i)
+		}
+	}
+	if len(r.Torrents) != 0 {
+		t.Errorf("torrents = %d, want 0", len(r.Torrents))
+	}
+}
+
+// multiClient 记录并发搜索数的客户端
+type multiClient struct {
+	*btsite.NexusPHPClient
+	running, peak *atomic.Int32
+}
+
+func (c *multiClient) Search(ctx context.Context, searchParams btsite.SearchParams) ([]btsite.SearchTorrent, error) {
+	n := c.running.Add(1)
+	defer c.running.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	code := c.Site().Code
+	return []btsite.SearchTorrent{{ID: code + "-1"}, {ID: code + "-2"}}, nil
+}
+
+func TestMultiSearch(t *testing.T) {
+	var running, peak atomic.Int32
+	err := btsite.RegisterSchema("go-btsite-multi", func(site *btsite.Site) btsite.ClientContext {
+		return &multiClient{btsite.NewNexusPHPClient(site), &running, &peak}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &btsite.AdaptCfg{}
+	var sites []*btsite.Site
+	for _, code := range []string{"a", "b", "c", "d"} {
+		cfg.Configs = append(cfg.Configs, btsite.Config{Config: siteadapt.Config{ID: code}, Schema: "go-btsite-multi"})
+		sites = append(sites, &btsite.Site{Code: code})
+	}
+	sites = append(sites, nil)
+	r := btsite.NewRegistry(cfg).MultiSearch(context.Background(), sites, btsite.SearchParams{}, btsite.MultiSearchOptions{Concurrency: 2})
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrency = %d, want 2", got)
+	}
+	if len(r.Torrents) != 8 {
+		t.Fatalf("torrents = %d, want 8", len(r.Torrents))
+	}
+	for i, torrent := range r.Torrents {
+		code := sites[i/2].Code
+		if torrent.SiteCode != code || !strings.HasPrefix(torrent.ID, code+"-") {
+			t.Errorf("torrents[%d] = %s/%s, want site %s", i, torrent.SiteCode, torrent.ID, code)
+		}
+	}
+	for i, status := range r.Sites[:4] {
+		if status.Err != nil || status.Count != 2 || status.SiteCode != sites[i].Code {
+			t.Errorf("sites[%d] = %+v", i, status)
+		}
+	}
+	if r.Sites[4].Err == nil {
+		t.Error("nil site: Err = nil, want error")
+	}
+}
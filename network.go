@@ -0,0 +1,141 @@
+package btsite
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// parseHeaders 解析站点自定义请求头，每行一个，格式为 key:value
+func parseHeaders(s string) map[string]string {
+	if len(s) == 0 {
+		return nil
+	}
+	headers := make(map[string]string)
+	for _, header := range strings.Split(s, "\n") {
+		kvs := strings.Split(strings.TrimSpace(header), ":")
+		if len(kvs) == 2 {
+			headers[kvs[0]] = kvs[1]
+		}
+	}
+	return headers
+}
+
+// 按代理地址和是否跳过证书校验共享的 Transport，相同网络配置的站点复用连接
+var siteTransports sync.Map
+
+type transportKey struct {
+	proxy              string
+	insecureSkipVerify bool
+}
+
+// siteTransport 根据站点的网络配置获取 Transport，Transport 优先于 Proxy 和 InsecureSkipVerify，
+// 只用于本包直接发起的请求，siteadapt 发起的请求不支持
+func siteTransport(site *Site) (http.RoundTripper, error) {
+	if site.Transport != nil {
+		return site.Transport, nil
+	}
+	if site.Proxy == "" && !site.InsecureSkipVerify {
+		return http.DefaultTransport, nil
+	}
+	key := transportKey{site.Proxy, site.InsecureSkipVerify}
+	if transport, ok := siteTransports.Load(key); ok {
+		return transport.(http.RoundTripper), nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if site.Proxy != "" {
+		proxy, err := url.Parse(site.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("代理地址错误: %s", site.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if site.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	actual, _ := siteTransports.LoadOrStore(key, transport)
+	return actual.(http.RoundTripper), nil
+}
+
+// fetch 使用站点的网络配置、UA、Cookie 和自定义请求头 GET 请求 params.path，params.params 作为 url 请求参数，返回响应体。
+// 与 data、list、raw 一样限流、重试，状态码非 2xx 时返回 SiteError。
+func fetch(params requestSiteParams) ([]byte, error) {
+	site := params.site
+	transport, err := siteTransport(site)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: transport}
+	rawUrl := params.path
+	if len(params.params) > 0 {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		for k, v := range params.params {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+		rawUrl = u.String()
+	}
+	var body []byte
+	err = execute(params, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+		if err != nil {
+			return err
+		}
+		if site.UserAgent != "" {
+			req.Header.Set("User-Agent", site.UserAgent)
+		}
+		if site.Cookie != "" {
+			req.Header.Set("Cookie", site.Cookie)
+		}
+		for k, v := range parseHeaders(site.Headers) {
+			req.Header.Set(k, v)
+		}
+		for k, v := range params.headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return newSiteError(params, 0, rawUrl, err)
+		}
+		defer resp.Body.Close()
+		// 重定向后的地址，用于判断是否重定向到登录页
+		finalUrl := resp.Request.URL.String()
+		var reader io.Reader = resp.Body
+		if params.maxSize > 0 {
+			// 多读一个字节判断是否超过限制
+			reader = io.LimitReader(resp.Body, params.maxSize+1)
+		}
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			return newSiteError(params, resp.StatusCode, finalUrl, err)
+		}
+		if params.maxSize > 0 && int64(len(b)) > params.maxSize {
+			return newSiteError(params, resp.StatusCode, finalUrl, fmt.Errorf("响应体超过 %d 字节", params.maxSize))
+		}
+		if isChallenge(resp, b) {
+			return newSiteError(params, resp.StatusCode, finalUrl, errChallengePage)
+		}
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return newSiteError(params, resp.StatusCode, finalUrl, fmt.Errorf("状态码异常: %s", resp.Status))
+		}
+		if finalUrl != rawUrl && isLoginUrl(finalUrl) {
+			return newSiteError(params, resp.StatusCode, finalUrl, errors.New("重定向到登录页"))
+		}
+		body = b
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
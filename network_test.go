@@ -0,0 +1,140 @@
+package btsite_test
+
+import (
+	"context"
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-siteadapt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	count atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRssUsesSiteTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "uid=1" || r.Header.Get("X-Test") != "1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`<rss><channel><item><title>Movie.2024.1080p</title>` +
+			`<link>https://example.com/details.php?id=1</link>` +
+			`<enclosure url="https://example.com/download.php?id=1" length="1024"/></item></channel></rss>`))
+	}))
+	defer srv.Close()
+	r := btsite.NewRegistry(&btsite.AdaptCfg{Configs: []btsite.Config{{
+		Config: siteadapt.Config{ID: "rss"},
+		Schema: "NexusPHP",
+	}}})
+	transport := &countingTransport{}
+	c, err := r.NewClientContext(&btsite.Site{
+		Code:      "rss",
+		Cookie:    "uid=1",
+		Headers:   "X-Test:1",
+		RssUrl:    srv.URL,
+		Transport: transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	torrents, err := c.Rss(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 1 || torrents[0].Title != "Movie.2024.1080p" ||
+		torrents[0].Enclosure != "https://example.com/download.php?id=1" {
+		t.Errorf("Rss() = %+v", torrents)
+	}
+	if got := transport.count.Load(); got != 1 {
+		t.Errorf("transport.count = %d, want 1", got)
+	}
+}
+
+func TestRequestCanceled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte("<table></table>"))
+	}))
+	defer srv.Close()
+	defer close(release)
+	r := btsite.NewRegistry(&btsite.AdaptCfg{Configs: []btsite.Config{{
+		Config: siteadapt.Config{ID: "cancel", Domain: srv.URL, RequestDefinitions: map[string]siteadapt.RequestDefinition{
+			"favicon": {Path: "favicon.ico"},
+		}},
+		Schema: "NexusPHP",
+	}}})
+	c, err := r.NewClientContext(&btsite.Site{Code: "cancel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Favicon(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Favicon() err = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Favicon() 返回耗时 %v, want ctx 超时后立即返回", d)
+	}
+}
+
+func TestApiRequestsUseSiteTransport(t *testing.T) {
+	transport := &countingTransport{}
+	c, _ := newFixtureClient(t, "UNIT3D", "unit3d", func(r *http.Request) string {
+		switch r.URL.Path {
+		case "/api/torrents/filter":
+			return "filter.json"
+		case "/api/torrents/5001":
+			return "torrent.json"
+		}
+		return ""
+	}, func(site *btsite.Site) {
+		site.ApiToken = "fixture-token"
+		site.Transport = transport
+	})
+	ctx := context.Background()
+	if _, err := c.Search(ctx, btsite.SearchParams{Keyword: "movie"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Details(ctx, "5001"); err != nil {
+		t.Fatal(err)
+	}
+	if got := transport.count.Load(); got != 2 {
+		t.Errorf("transport.count = %d, want 2", got)
+	}
+}
+
+func TestApiRequestUsesProxy(t *testing.T) {
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.URL.Host)
+		http.ServeFile(w, r, filepath.Join("testdata", "unit3d", "filter.json"))
+	}))
+	defer proxy.Close()
+	c, _ := newFixtureClient(t, "UNIT3D", "unit3d", func(r *http.Request) string {
+		return ""
+	}, func(site *btsite.Site) {
+		site.Domain = "http://site.invalid/"
+		site.ApiToken = "fixture-token"
+		site.Proxy = proxy.URL
+	})
+	torrents, err := c.Search(context.Background(), btsite.SearchParams{Keyword: "movie"})
+	if err != nil || len(torrents) == 0 {
+		t.Errorf("Search() = %v, %v", torrents, err)
+	}
+	if len(hosts) != 1 || hosts[0] != "site.invalid" {
+		t.Errorf("proxy hosts = %v, want [site.invalid]", hosts)
+	}
+}
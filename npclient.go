@@ -1,24 +1,57 @@
 package btsite
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/heibizi/go-siteadapt"
 	"net/url"
 	"strings"
-	"time"
 )
 
 type (
-	// npClient NexusPHP 客户端
-	npClient struct {
+	// NexusPHPClient NexusPHP 客户端，也是其他架构客户端的基础实现，可以嵌入后重写需要的方法
+	NexusPHPClient struct {
 		site *Site
 	}
 )
 
-func (c *npClient) Favicon() ([]byte, error) {
+// NexusPHP 搜索的 spstate 参数，不支持 70% 下载
+var npPromotions = map[Promotion]string{
+	PromotionNormal: "1",
+	PromotionFree:   "2",
+	Promotion2X:     "3",
+	Promotion2XFree: "4",
+	PromotionHalf:   "5",
+	Promotion2XHalf: "6",
+	PromotionThirty: "7",
+}
+
+// NexusPHP 搜索的 sort 参数
+var npSortFields = map[SortField]string{
+	SortByTitle:    "1",
+	SortByTime:     "4",
+	SortBySize:     "5",
+	SortByGrabs:    "6",
+	SortBySeeders:  "7",
+	SortByLeechers: "8",
+}
+
+// NewNexusPHPClient 创建 NexusPHP 客户端
+func NewNexusPHPClient(site *Site) *NexusPHPClient {
+	return &NexusPHPClient{site: site}
+}
+
+// Site 客户端对应的站点
+func (c *NexusPHPClient) Site() *Site {
+	return c.site
+}
+
+func (c *NexusPHPClient) Favicon(ctx context.Context) ([]byte, error) {
 	var data []byte
 	err := raw(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdFavicon,
 	}, func(result siteadapt.RawResult) {
@@ -30,9 +63,10 @@ func (c *npClient) Favicon() ([]byte, error) {
 	return data, nil
 }
 
-func (c *npClient) UserBasicInfo() (UserBasicInfo, error) {
+func (c *NexusPHPClient) UserBasicInfo(ctx context.Context) (UserBasicInfo, error) {
 	var ud UserBasicInfo
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdUserBasicInfo,
 	}, &ud, nil)
@@ -47,12 +81,12 @@ func (c *npClient) UserBasicInfo() (UserBasicInfo, error) {
 		totalCopper := ud.Gold*100*100 + ud.Silver*100 + ud.Copper
 		ud.Bonus = totalCopper
 	}
-	sc, err := SiteHelper.GetConfigByCode(c.site.Code)
+	sc, err := siteConfig(c.site)
 	if err != nil {
 		return ud, err
 	}
 	if sc.CountMessage {
-		messages, err := c.UnreadMessages(false)
+		messages, err := c.UnreadMessages(ctx, false)
 		if err != nil {
 			return ud, err
 		}
@@ -61,9 +95,10 @@ func (c *npClient) UserBasicInfo() (UserBasicInfo, error) {
 	return ud, nil
 }
 
-func (c *npClient) UserDetails() (UserDetails, error) {
+func (c *NexusPHPClient) UserDetails(ctx context.Context) (UserDetails, error) {
 	var ud UserDetails
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdUserDetails,
 	}, &ud, nil)
@@ -73,52 +108,30 @@ func (c *npClient) UserDetails() (UserDetails, error) {
 	return ud, nil
 }
 
-func (c *npClient) Search(searchParams SearchParams) ([]SearchTorrent, error) {
-	sh := SiteHelper
+func (c *NexusPHPClient) Search(ctx context.Context, searchParams SearchParams) ([]SearchTorrent, error) {
 	site := c.site
-	sc, err := sh.GetConfigByCode(site.Code)
+	sc, err := siteConfig(site)
 	if err != nil {
 		return nil, newError(site, err, "未获取到站点配置")
 	}
-	var params url.Values
-	var paramsEnv = map[string]string{
-		"keyword": searchParams.Keyword,
+	_, promotion := npPromotions[searchParams.Promotion]
+	local := localFilter{
+		promotion: !promotion,
+		// 有关键字时不能同时按 IMDb 编号搜索，不支持按豆瓣编号搜索
+		imdb:   len(searchParams.Keyword) > 0,
+		douban: true,
 	}
-	if len(searchParams.Keyword) > 0 {
-		params = url.Values{
-			"search_mode": {"0"},
-			"page":        {fmt.Sprintf("%d", searchParams.Page)},
-			"notnewword":  {"1"},
-		}
-		category := &sc.Categories
-		if category != nil {
-			var cats []AdaptMediaCat
-			switch searchParams.MediaType {
-			case Movie:
-				cats = category.Movie
-			case Tv:
-				cats = category.TV
-			default:
-				cats = append(category.Movie, category.TV...)
-			}
-			for _, cat := range cats {
-				field := category.Field
-				if len(field) > 0 {
-					value := params.Get(field)
-					params.Add(field, value+category.Delimiter+cat.ID)
-				} else {
-					params.Add(cat.ID, "1")
-				}
-			}
-		}
-	} else {
-		params = url.Values{
-			"page": {fmt.Sprintf("%d", searchParams.Page)},
-		}
+	if err := idSearchError(site, searchParams, local); err != nil {
+		return nil, err
+	}
+	params, keyword := npSearchParams(sc, searchParams)
+	var paramsEnv = map[string]string{
+		"keyword": keyword,
 	}
 	var torrents []torrent
 	requestUrl := ""
 	err = data(requestSiteParams{
+		ctx:    ctx,
 		site:   site,
 		reqId:  requestIdSearch,
 		params: params,
@@ -129,6 +142,7 @@ func (c *npClient) Search(searchParams SearchParams) ([]SearchTorrent, error) {
 	if err != nil {
 		return nil, newError(site, err, "解析种子列表失败")
 	}
+	loc := siteLocation(sc)
 	var searchTorrents []SearchTorrent
 	for _, torrent := range torrents {
 		var pageUrl string
@@ -164,19 +178,88 @@ func (c *npClient) Search(searchParams SearchParams) ([]SearchTorrent, error) {
 			Size:                 torrent.Size,
 			DownloadVolumeFactor: torrent.DownloadVolumeFactor,
 			UploadVolumeFactor:   torrent.UploadVolumeFactor,
-			PubDate:              torrent.DateAdded,
-			DateElapsed:          torrent.DateElapsed,
+			PubDate:              parseTime(torrent.DateAdded, loc),
+			PubDateRaw:           torrent.DateAdded,
+			DateElapsed:          parseDuration(torrent.DateElapsed),
+			DateElapsedRaw:       torrent.DateElapsed,
 			HrDays:               torrent.HrDays,
 			HitAndRun:            torrent.HrDays > 0,
 			Labels:               torrent.Labels,
+			InfoHash:             torrentInfoHash(torrent.InfoHash, enclosure),
+			IMDbID:               normalizeIMDbID(torrent.IMDbID),
+			DoubanID:             normalizeDoubanID(torrent.DoubanID),
+			PosterURL:            joinURLs(requestUrl, torrent.Poster),
+			Subtitle:             torrent.Subtitle,
+			PromotionExpiresAt:   parseTime(torrent.FreeDeadline, loc),
 		}
 		searchTorrents = append(searchTorrents, search)
 	}
-	return searchTorrents, nil
+	return searchResult(ctx, searchParams, searchTorrents, local), nil
+}
+
+// npSearchParams NexusPHP 的搜索参数，返回 url 请求参数和关键字，关键字为空时按 IMDb 编号搜索
+func npSearchParams(sc Config, searchParams SearchParams) (url.Values, string) {
+	keyword := searchParams.Keyword
+	params := url.Values{
+		"page": {fmt.Sprintf("%d", searchParams.Page)},
+	}
+	if len(keyword) == 0 && len(searchParams.IMDbID) > 0 {
+		// 按 IMDb 编号搜索
+		keyword = searchParams.IMDbID
+		params.Set("search_area", "4")
+	}
+	category := &sc.Categories
+	var cats []AdaptMediaCat
+	if len(keyword) > 0 {
+		params.Set("search_mode", "0")
+		params.Set("notnewword", "1")
+		switch searchParams.MediaType {
+		case Movie:
+			cats = category.Movie
+		case Tv:
+			cats = category.TV
+		default:
+			cats = append(category.Movie, category.TV...)
+		}
+	}
+	if len(searchParams.Categories) > 0 {
+		cats = nil
+		for _, id := range searchParams.Categories {
+			cats = append(cats, AdaptMediaCat{ID: id})
+		}
+	}
+	for _, cat := range cats {
+		field := category.Field
+		if len(field) > 0 {
+			value := params.Get(field)
+			params.Set(field, value+category.Delimiter+cat.ID)
+		} else {
+			params.Add(cat.ID, "1")
+		}
+	}
+	npSearchFilters(params, searchParams)
+	return params, keyword
+}
+
+// npSearchFilters NexusPHP 的促销、断种和排序参数
+func npSearchFilters(params url.Values, searchParams SearchParams) {
+	if spstate, ok := npPromotions[searchParams.Promotion]; ok {
+		params.Set("spstate", spstate)
+	}
+	if searchParams.IncludeDead {
+		params.Set("incldead", "0")
+	}
+	if sort, ok := npSortFields[searchParams.SortBy]; ok {
+		params.Set("sort", sort)
+		params.Set("type", "desc")
+		if searchParams.SortOrder == SortAsc {
+			params.Set("type", "asc")
+		}
+	}
 }
 
-func (c *npClient) SeedingStatistics() (SeedingStatistics, error) {
-	sc, err := SiteHelper.GetConfigByCode(c.site.Code)
+func (c *NexusPHPClient) SeedingStatistics(ctx context.Context) (SeedingStatistics, error) {
+	sc, err := siteConfig(c.site)
 	if err != nil {
 		return SeedingStatistics{}, err
 	}
@@ -185,6 +268,7 @@ func (c *npClient) SeedingStatistics() (SeedingStatistics, error) {
 	if exists && rd.List == nil {
 		var ss SeedingStatistics
 		err := data(requestSiteParams{
+			ctx:   ctx,
 			site:  c.site,
 			reqId: requestIdSeedingStatistics,
 		}, &ss, nil)
@@ -195,19 +279,21 @@ func (c *npClient) SeedingStatistics() (SeedingStatistics, error) {
 	} else {
 		// 分页统计做种信息
 		var seedingList []seeding
-		currentPageSeedingList, nextPage, err := c.CurrentPageSeeding("")
+		currentPageSeedingList, nextPage, err := c.currentPageSeeding(ctx, "")
 		if err != nil {
 			return SeedingStatistics{}, err
 		}
 		seedingList = append(seedingList, currentPageSeedingList...)
 		for len(nextPage) > 0 {
-			currentPageSeedingList, nextPageTmp, err := c.CurrentPageSeeding(nextPage)
+			if err := waitNextPage(ctx, c.site); err != nil {
+				return SeedingStatistics{}, err
+			}
+			currentPageSeedingList, nextPageTmp, err := c.currentPageSeeding(ctx, nextPage)
 			if err != nil {
 				return SeedingStatistics{}, err
 			}
 			seedingList = append(seedingList, currentPageSeedingList...)
 			nextPage = nextPageTmp
-			time.Sleep(500 * time.Millisecond)
 		}
 		var size int64 = 0
 		for _, seeding := range seedingList {
@@ -220,11 +306,12 @@ func (c *npClient) SeedingStatistics() (SeedingStatistics, error) {
 	}
 }
 
-// CurrentPageSeeding 当前页做种信息以及下一页链接地址
-func (c *npClient) CurrentPageSeeding(url string) ([]seeding, string, error) {
+// currentPageSeeding 当前页做种信息以及下一页链接地址
+func (c *NexusPHPClient) currentPageSeeding(ctx context.Context, url string) ([]seeding, string, error) {
 	var seedingList []seeding
 	nextPage := ""
 	err := list(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdSeedingStatistics,
 		path:  url,
@@ -237,8 +324,8 @@ func (c *npClient) CurrentPageSeeding(url string) ([]seeding, string, error) {
 	return seedingList, nextPage, nil
 }
 
-func (c *npClient) MyHr() ([]HrTorrent, error) {
-	sc, err := SiteHelper.GetConfigByCode(c.site.Code)
+func (c *NexusPHPClient) MyHr(ctx context.Context) ([]HrTorrent, error) {
+	sc, err := siteConfig(c.site)
 	if err != nil {
 		return nil, err
 	}
@@ -247,19 +334,25 @@ func (c *npClient) MyHr() ([]HrTorrent, error) {
 	}
 	var hrList []HrTorrent
 	err = list(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdMyHr,
 	}, &hrList, nil)
 	if err != nil {
 		return nil, newError(c.site, err, "HR列表失败")
 	}
+	for i := range hrList {
+		hrList[i].NeedSeedDuration = parseDuration(hrList[i].NeedSeedTime)
+		hrList[i].RemainingInspectionDuration = parseDuration(hrList[i].RemainingInspectionTime)
+	}
 	return hrList, nil
 }
 
-func (c *npClient) UnreadMessages(detail bool) ([]Message, error) {
+func (c *NexusPHPClient) UnreadMessages(ctx context.Context, detail bool) ([]Message, error) {
 	var o []Message
 	requestUrl := ""
 	err := list(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdUnreadMessages,
 	}, &o, func(result siteadapt.ListResult) {
@@ -275,7 +368,7 @@ func (c *npClient) UnreadMessages(detail bool) ([]Message, error) {
 			if err != nil {
 				return nil, err
 			}
-			detail, err := c.unreadMessageDetail(detailUrl)
+			detail, err := c.unreadMessageDetail(ctx, detailUrl)
 			if err != nil {
 				return nil, err
 			}
@@ -285,9 +378,10 @@ func (c *npClient) UnreadMessages(detail bool) ([]Message, error) {
 	return o, nil
 }
 
-func (c *npClient) LatestNotice() (*Notice, error) {
+func (c *NexusPHPClient) LatestNotice(ctx context.Context) (*Notice, error) {
 	var notice Notice
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdLatestNotice,
 	}, &notice, nil)
@@ -301,18 +395,15 @@ func (c *npClient) LatestNotice() (*Notice, error) {
 	return &notice, nil
 }
 
-func (c *npClient) Rss() ([]RssTorrent, error) {
-	rd := siteadapt.RequestDefinition{
-		Parser: "None",
-		Method: "GET",
-		Path:   c.site.RssUrl,
-	}
-	var data []byte
-	err := raw(requestSiteParams{
-		site: c.site,
-		rd:   &rd,
-	}, func(result siteadapt.RawResult) {
-		data = result.Data
+// 拉取 RSS，不对应请求定义，用于限流、重试配置和异常信息
+const requestIdRss requestId = "rss"
+
+func (c *NexusPHPClient) Rss(ctx context.Context) ([]RssTorrent, error) {
+	data, err := fetch(requestSiteParams{
+		ctx:   ctx,
+		site:  c.site,
+		reqId: requestIdRss,
+		path:  c.site.RssUrl,
 	})
 	if err != nil {
 		return nil, newError(c.site, err, "获取 RSS 数据异常")
@@ -348,15 +439,17 @@ func (c *npClient) Rss() ([]RssTorrent, error) {
 			Description: item.Description,
 			Link:        item.Link,
 			PubDate:     siteadapt.GetTimeStamp(item.PubDate),
+			Parsed:      parseRelease(item.Title),
 		})
 	}
 	return torrents, nil
 }
 
 // unreadMessageDetail 未读消息详情
-func (c *npClient) unreadMessageDetail(url string) (Message, error) {
+func (c *NexusPHPClient) unreadMessageDetail(ctx context.Context, url string) (Message, error) {
 	var message Message
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdUnreadMessageDetail,
 		path:  url,
@@ -367,10 +460,11 @@ func (c *npClient) unreadMessageDetail(url string) (Message, error) {
 	return message, nil
 }
 
-func (c *npClient) SignIn() (SignInResult, error) {
+func (c *NexusPHPClient) SignIn(ctx context.Context) (SignInResult, error) {
 	// 尝试获取用户基础信息，既可以判断是否需要已登录也可以用于模拟登录
-	ubi, err := c.UserBasicInfo()
-	if err != nil {
+	ubi, err := c.UserBasicInfo(WithoutCache(ctx))
+	// 重定向到登录页时返回 ErrNotLoggedIn，ubi.IsLogin 为 false
+	if err != nil && !errors.Is(err, ErrNotLoggedIn) {
 		return SignInResult{}, err
 	}
 	if !ubi.IsLogin {
@@ -385,7 +479,7 @@ func (c *npClient) SignIn() (SignInResult, error) {
 			Message: "今日已签到",
 		}, nil
 	}
-	sc, err := SiteHelper.GetConfigByCode(c.site.Code)
+	sc, err := siteConfig(c.site)
 	if err != nil {
 		return SignInResult{}, err
 	}
@@ -400,6 +494,7 @@ func (c *npClient) SignIn() (SignInResult, error) {
 	r := signInResult{}
 	statusCode := 0
 	err = data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdSignIn,
 	}, &r, func(result siteadapt.DataResult) {
@@ -428,14 +523,30 @@ func (c *npClient) SignIn() (SignInResult, error) {
 	}, nil
 }
 
-func (c *npClient) GetDownloadUrl(torrent SearchTorrent) (string, error) {
-	return torrent.Enclosure, nil
+// GetDownloadUrl 优先使用搜索结果的下载地址，没有时 NexusPHP 架构的站点根据 ID 拼接 download.php?id=，其他架构返回空
+func (c *NexusPHPClient) GetDownloadUrl(ctx context.Context, torrent SearchTorrent) (string, error) {
+	if len(torrent.Enclosure) > 0 || len(torrent.ID) == 0 {
+		return torrent.Enclosure, nil
+	}
+	sc, err := siteConfig(c.site)
+	if err != nil {
+		return "", newError(c.site, err, "未获取到站点配置")
+	}
+	if sc.Schema != string(siteSchemaNexusPHP) && sc.ReuseSchema != string(siteSchemaNexusPHP) {
+		return "", nil
+	}
+	domain, err := SiteHelper.GetDomain(*c.site)
+	if err != nil {
+		return "", err
+	}
+	return JoinURL(domain, "download.php?id="+url.QueryEscape(torrent.ID))
 }
 
-func (c *npClient) Details(id string) (TorrentDetail, error) {
+func (c *NexusPHPClient) Details(ctx context.Context, id string) (TorrentDetail, error) {
 	env := map[string]string{"id": id}
 	torrentDetail := TorrentDetail{}
 	err := data(requestSiteParams{
+		ctx:   ctx,
 		site:  c.site,
 		reqId: requestIdDetails,
 		env:   env,
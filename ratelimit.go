@@ -0,0 +1,105 @@
+package btsite
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimit 站点限流配置，令牌桶算法
+	RateLimit struct {
+		RPS   float64 `mapstructure:"rps"`   // 每秒请求数，<= 0 时不限流
+		Burst int     `mapstructure:"burst"` // 桶容量，即允许的突发请求数，<= 0 时为 1
+	}
+	// rateLimiter 令牌桶限流器
+	rateLimiter struct {
+		mu     sync.Mutex
+		limit  RateLimit
+		tokens float64
+		last   time.Time
+	}
+)
+
+// defaultPageInterval 站点未配置限流时，做种统计等连续分页请求之间的间隔
+const defaultPageInterval = 500 * time.Millisecond
+
+// 站点限流器，按站点唯一标识共享，同一站点的所有客户端共用一个限流器
+var siteLimiters = struct {
+	sync.Mutex
+	m map[string]*rateLimiter
+}{m: make(map[string]*rateLimiter)}
+
+// limiterFor 获取站点的限流器，配置变化时更新限流器的配置
+func limiterFor(code string, limit RateLimit) *rateLimiter {
+	siteLimiters.Lock()
+	defer siteLimiters.Unlock()
+	l, ok := siteLimiters.m[code]
+	if !ok {
+		l = newRateLimiter(limit)
+		siteLimiters.m[code] = l
+		return l
+	}
+	l.setLimit(limit)
+	return l
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	l := &rateLimiter{last: time.Now()}
+	l.setLimit(limit)
+	l.tokens = float64(l.limit.Burst)
+	return l
+}
+
+func (l *rateLimiter) setLimit(limit RateLimit) {
+	if limit.Burst <= 0 {
+		limit.Burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+// wait 获取一个令牌，令牌不足时等待，ctx 结束时归还令牌并返回 ctx.Err()
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.limit.RPS <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.limit.RPS
+	if burst := float64(l.limit.Burst); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	l.tokens--
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.limit.RPS * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay == 0 {
+		return nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// waitNextPage 连续分页请求前等待，站点配置了限流时由限流器控制请求频率，不额外等待，
+// 未配置限流时等待 defaultPageInterval，避免连续请求站点
+func waitNextPage(ctx context.Context, site *Site) error {
+	sc, err := siteConfig(site)
+	if err != nil {
+		return err
+	}
+	if sc.RateLimit.RPS > 0 {
+		return nil
+	}
+	return sleep(ctx, defaultPageInterval)
+}
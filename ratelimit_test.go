@@ -0,0 +1,67 @@
+package btsite
+
+import (
+	"context"
+	"github.com/heibizi/go-siteadapt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	l := newRateLimiter(RateLimit{RPS: 20, Burst: 2})
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 突发 2 个，剩余 2 个需要等待约 100ms
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("elapsed = %v, want >= 80ms", elapsed)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := newRateLimiter(RateLimit{RPS: 0.1})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); err != nil {
+		t.Fatalf("first wait err = %v", err)
+	}
+	if err := l.wait(ctx); err == nil {
+		t.Error("second wait err = nil, want ctx error")
+	}
+}
+
+func TestLimiterForSharedBySite(t *testing.T) {
+	a := limiterFor("go-btsite-limiter", RateLimit{RPS: 1})
+	b := limiterFor("go-btsite-limiter", RateLimit{RPS: 2})
+	if a != b {
+		t.Fatal("limiterFor() returned different limiters for the same site")
+	}
+	if a.limit.RPS != 2 {
+		t.Errorf("limit.RPS = %v, want 2", a.limit.RPS)
+	}
+}
+
+func TestWaitNextPage(t *testing.T) {
+	r := NewRegistry(&AdaptCfg{Configs: []Config{
+		{Config: siteadapt.Config{ID: "unlimited"}, Schema: string(siteSchemaNexusPHP)},
+		{Config: siteadapt.Config{ID: "limited"}, Schema: string(siteSchemaNexusPHP), RateLimit: RateLimit{RPS: 1}},
+	}})
+	for code, want := range map[string]time.Duration{"unlimited": defaultPageInterval, "limited": 0} {
+		start := time.Now()
+		if err := waitNextPage(context.Background(), &Site{Code: code, registry: r}); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < want || elapsed > want+200*time.Millisecond {
+			t.Errorf("%s: waitNextPage() elapsed = %v, want %v", code, elapsed, want)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitNextPage(ctx, &Site{Code: "unlimited", registry: r}); err != context.Canceled {
+		t.Errorf("waitNextPage() err = %v, want Canceled", err)
+	}
+}
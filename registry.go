@@ -0,0 +1,155 @@
+package btsite
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Registry 站点适配配置注册表，持有一份 AdaptCfg，多个 Registry 之间互不影响，
+// 可用于同时运行多个版本的站点适配配置
+type Registry struct {
+	mu    sync.RWMutex
+	cfg   AdaptCfg
+	cache Cache
+}
+
+// 包级函数使用的默认注册表
+var defaultRegistry = NewRegistry(nil)
+
+// NewRegistry 创建注册表，cfg 为空时创建空注册表。
+// 注册表持有 cfg 的深拷贝，之后对 cfg 的修改不会影响注册表
+func NewRegistry(cfg *AdaptCfg) *Registry {
+	r := &Registry{}
+	if cfg != nil {
+		r.cfg = deepCopy(*cfg)
+	}
+	return r
+}
+
+// DefaultRegistry 默认注册表，InitConfig、SetConfig、NewClient 等包级函数都使用它
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// SetConfig 替换注册表持有的站点适配配置，同 NewRegistry，注册表持有 cfg 的深拷贝
+func (r *Registry) SetConfig(cfg *AdaptCfg) {
+	cfg2 := deepCopy(*cfg)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cfg = cfg2
+}
+
+// Config 获取注册表持有的站点适配配置，返回深拷贝，修改结果不会影响注册表
+func (r *Registry) Config() AdaptCfg {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return deepCopy(r.cfg)
+}
+
+// GetConfigByCode 根据 code 获取站点配置，返回的配置与注册表共享切片和 map，不要修改
+func (r *Registry) GetConfigByCode(code string) (Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, siteConfig := range r.cfg.Configs {
+		if siteConfig.ID == code {
+			return siteConfig, nil
+		}
+	}
+	return Config{}, fmt.Errorf("%w: %s", ErrConfigNotFound, code)
+}
+
+// AllSupportedSites 所有支持的站点配置，返回副本，修改切片不会影响注册表
+func (r *Registry) AllSupportedSites() []Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Config(nil), r.cfg.Configs...)
+}
+
+// NewClient 使用当前注册表的站点配置创建客户端
+func (r *Registry) NewClient(site *Site) (Client, error) {
+	c, err := r.NewClientContext(site)
+	if err != nil {
+		return nil, err
+	}
+	return AdaptClient(c), nil
+}
+
+// NewClientContext 同 NewClient，创建支持 context 的客户端。
+// 客户端使用 site 的副本，之后对 site 的修改不会影响已创建的客户端。
+func (r *Registry) NewClientContext(site *Site) (ClientContext, error) {
+	sc, err := r.GetConfigByCode(site.Code)
+	if err != nil {
+		return nil, err
+	}
+	constructor, ok := clientFactory(sc.Schema)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, sc.Schema)
+	}
+	s := *site
+	s.registry = r
+	return constructor(&s), nil
+}
+
+// registryOf 获取站点所属的注册表
+func registryOf(site *Site) *Registry {
+	if site.registry != nil {
+		return site.registry
+	}
+	return defaultRegistry
+}
+
+// siteConfig 从站点所属的注册表获取站点配置
+func siteConfig(site *Site) (Config, error) {
+	return registryOf(site).GetConfigByCode(site.Code)
+}
+
+// deepCopy 深拷贝 v，复制切片、map、指针和接口指向的值
+func deepCopy[T any](v T) T {
+	var out T
+	copyValue(reflect.ValueOf(&out).Elem(), reflect.ValueOf(v))
+	return out
+}
+
+func copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if !src.IsNil() {
+			dst.Set(reflect.New(src.Type().Elem()))
+			copyValue(dst.Elem(), src.Elem())
+		}
+	case reflect.Interface:
+		if !src.IsNil() {
+			v := reflect.New(src.Elem().Type()).Elem()
+			copyValue(v, src.Elem())
+			dst.Set(v)
+		}
+	case reflect.Slice:
+		if !src.IsNil() {
+			dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+			for i := 0; i < src.Len(); i++ {
+				copyValue(dst.Index(i), src.Index(i))
+			}
+		}
+	case reflect.Map:
+		if !src.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+			iter := src.MapRange()
+			for iter.Next() {
+				v := reflect.New(src.Type().Elem()).Elem()
+				copyValue(v, iter.Value())
+				dst.SetMapIndex(iter.Key(), v)
+			}
+		}
+	case reflect.Struct:
+		// 先整体复制，未导出的字段保持浅拷贝
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
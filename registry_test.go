@@ -0,0 +1,92 @@
+package btsite_test
+
+import (
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-siteadapt"
+	"testing"
+)
+
+func TestRegistryIsolation(t *testing.T) {
+	newCfg := func(code string) *btsite.AdaptCfg {
+		return &btsite.AdaptCfg{Configs: []btsite.Config{{
+			Config: siteadapt.Config{ID: code},
+			Schema: "NexusPHP",
+		}}}
+	}
+	stable := btsite.NewRegistry(newCfg("stable"))
+	canary := btsite.NewRegistry(newCfg("canary"))
+	if _, err := stable.GetConfigByCode("stable"); err != nil {
+		t.Errorf("stable.GetConfigByCode(stable) err = %v", err)
+	}
+	if _, err := stable.GetConfigByCode("canary"); err == nil {
+		t.Error("stable.GetConfigByCode(canary) err = nil, want error")
+	}
+	if _, err := canary.NewClient(&btsite.Site{Code: "canary"}); err != nil {
+		t.Errorf("canary.NewClient(canary) err = %v", err)
+	}
+	if _, err := canary.NewClient(&btsite.Site{Code: "stable"}); err == nil {
+		t.Error("canary.NewClient(stable) err = nil, want error")
+	}
+}
+
+func TestRegistryCopies(t *testing.T) {
+	cfg := &btsite.AdaptCfg{Configs: []btsite.Config{{
+		Config: siteadapt.Config{ID: "copy"},
+		Schema: "NexusPHP",
+	}}}
+	r := btsite.NewRegistry(cfg)
+	r.AllSupportedSites()[0].ID = "changed"
+	if _, err := r.GetConfigByCode("copy"); err != nil {
+		t.Errorf("修改 AllSupportedSites() 的结果影响了注册表: %v", err)
+	}
+	old := btsite.DefaultRegistry().Config()
+	defer btsite.DefaultRegistry().SetConfig(&old)
+	btsite.DefaultRegistry().SetConfig(cfg)
+	for name, r := range map[string]*btsite.Registry{"custom": r, "default": btsite.DefaultRegistry()} {
+		site := &btsite.Site{Code: "copy", Name: "before"}
+		c, err := r.NewClientContext(site)
+		if err != nil {
+			t.Fatal(err)
+		}
+		site.Name = "after"
+		if got := c.(*btsite.NexusPHPClient).Site().Name; got != "before" {
+			t.Errorf("%s: client site name = %s, want before", name, got)
+		}
+	}
+}
+
+func TestRegistryDeepCopiesConfig(t *testing.T) {
+	cfg := &btsite.AdaptCfg{
+		Common: map[string]btsite.Config{"NexusPHP": {Schema: "NexusPHP"}},
+		Configs: []btsite.Config{{
+			Config: siteadapt.Config{ID: "copy", RequestDefinitions: map[string]siteadapt.RequestDefinition{
+				"search": {Path: "torrents.php", Params: map[string]string{"a": "1"}},
+			}},
+			Schema: "NexusPHP",
+			Cache:  map[string]int{"search": 60},
+		}},
+	}
+	r := btsite.NewRegistry(cfg)
+	var r2 btsite.Registry
+	r2.SetConfig(cfg)
+	cfg.Configs[0].ID = "changed"
+	cfg.Configs[0].RequestDefinitions["search"].Params["a"] = "2"
+	cfg.Configs[0].Cache["search"] = 0
+	delete(cfg.Common, "NexusPHP")
+	for name, r := range map[string]*btsite.Registry{"NewRegistry": r, "SetConfig": &r2} {
+		sc, err := r.GetConfigByCode("copy")
+		if err != nil {
+			t.Fatalf("%s: 修改 cfg 影响了注册表: %v", name, err)
+		}
+		if sc.RequestDefinitions["search"].Params["a"] != "1" || sc.Cache["search"] != 60 {
+			t.Errorf("%s: 修改 cfg 的 map 影响了注册表: %+v", name, sc)
+		}
+		if _, ok := r.Config().Common["NexusPHP"]; !ok {
+			t.Errorf("%s: 修改 cfg.Common 影响了注册表", name)
+		}
+	}
+	r.Config().Configs[0].Cache["search"] = 1
+	if sc, _ := r.GetConfigByCode("copy"); sc.Cache["search"] != 60 {
+		t.Errorf("修改 Config() 的结果影响了注册表: %+v", sc.Cache)
+	}
+}
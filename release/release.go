@@ -0,0 +1,264 @@
+// Package release 解析种子标题中的发布名，如 Movie.Name.2023.2160p.UHD.BluRay.REMUX.HDR.HEVC.Atmos-GROUP，
+// 获取片名、年份、季集、分辨率、来源、编码、HDR、音频、发布组和版本等信息。
+//
+// 解析基于常见的命名规范，无法识别的部分忽略，不保证所有站点的标题都能完整解析。
+package release
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Info 发布名解析结果，无法识别的字段为零值
+type Info struct {
+	Title        string   // 片名，分隔符替换为空格
+	Year         int      // 年份
+	SeasonStart  int      // 开始季，如 S01-S03 为 1
+	SeasonEnd    int      // 结束季，单季时与 SeasonStart 相同
+	EpisodeStart int      // 开始集，如 S01E01-E03 为 1
+	EpisodeEnd   int      // 结束集，单集时与 EpisodeStart 相同
+	Resolution   string   // 分辨率：2160p、1080p、1080i、720p、576p、480p
+	Source       string   // 来源：BluRay、BDRip、WEB-DL、WEBRip、HDTV、DVD、DVDRip
+	Remux        bool     // 是否为 Remux
+	Codec        string   // 视频编码：HEVC、AVC、AV1、VC-1、MPEG-2
+	HDR          []string // HDR 格式：DV、HDR10+、HDR10、HDR、HLG
+	Audio        []string // 音频格式：Atmos、TrueHD、DTS:X、DTS-HD MA、DTS、DD+、DD、AAC、FLAC、LPCM、Opus
+	Channels     string   // 声道，如 5.1、7.1
+	Group        string   // 发布组
+	Edition      string   // 版本：Extended、Director's Cut、Unrated、Uncut、Remastered、IMAX、Criterion、Theatrical
+}
+
+// tag 单个标签的匹配规则，name 为匹配后的标准名称
+type tag struct {
+	name string
+	re   *regexp.Regexp
+}
+
+const (
+	// 标签的左右边界，Go 的正则不支持环视，边界字符会被匹配，所以位置取第 1 个分组
+	left  = `(?i)(?:^|[\s.\[\]()_\-/+])(`
+	right = `)(?:$|[\s.\[\]()_\-/+,])`
+)
+
+func newTag(name string, pattern string) tag {
+	return tag{name, regexp.MustCompile(left + pattern + right)}
+}
+
+var (
+	extRegexp          = regexp.MustCompile(`(?i)\.(mkv|mp4|avi|ts|m2ts|iso|torrent)$`)
+	leadingGroupRegexp = regexp.MustCompile(`^\[([^\]]+)\]\s*`)
+	groupRegexp        = regexp.MustCompile(`-([A-Za-z0-9@&_]+)$`)
+	yearRegexp         = regexp.MustCompile(left + `(?:19|20)\d{2}` + right)
+	episodeRegexp      = regexp.MustCompile(left + `S(\d{1,3})E(\d{1,4})(?:-?E(\d{1,4})|-(\d{1,4}))?` + right)
+	seasonRegexp       = regexp.MustCompile(left + `S(\d{1,3})(?:-S?(\d{1,3}))?` + right)
+	cnSeasonRegexp     = regexp.MustCompile(`第\s*(\d{1,3})\s*季`)
+	cnEpisodeRegexp    = regexp.MustCompile(`第\s*(\d{1,4})(?:\s*-\s*(\d{1,4}))?\s*集`)
+	channelsRegexp     = regexp.MustCompile(`(?i)(?:^|[\s.\-_A-Za-z+])([1-9]\.[0-2])(?:$|[\s.\-_\]])`)
+	titleSepRegexp     = regexp.MustCompile(`[._]+|\s+`)
+	// 动漫的绝对集数，如 [GROUP] Title - 01 [1080p]、Title - 01-12、Title - 01v2
+	absEpisodeRegexp = regexp.MustCompile(`\s-\s(\d{1,4})(?:\s*-\s*(\d{1,4}))?(?:v\d)?(?:$|[\s\[(])`)
+)
+
+// 不是发布组的结尾，如 WEB-DL、DTS-HD MA
+var notGroups = map[string]bool{"DL": true, "HD": true, "MA": true, "X": true, "RIP": true}
+
+var (
+	resolutions = []tag{
+		newTag("2160p", `2160p|4K|UHD`),
+		newTag("1080p", `1080p`),
+		newTag("1080i", `1080i`),
+		newTag("720p", `720p`),
+		newTag("576p", `576p`),
+		newTag("480p", `480p`),
+	}
+	sources = []tag{
+		newTag("BDRip", `BDRip|BRRip`),
+		newTag("BluRay", `Blu-?Ray|BD|BDMV`),
+		newTag("WEBRip", `WEB-?Rip`),
+		newTag("WEB-DL", `WEB-?DL|WEB`),
+		newTag("HDTV", `HDTV`),
+		newTag("DVDRip", `DVDRip`),
+		newTag("DVD", `DVD|DVD5|DVD9`),
+	}
+	remux  = newTag("Remux", `Remux`)
+	codecs = []tag{
+		newTag("HEVC", `HEVC|[xh]\.?265`),
+		newTag("AVC", `AVC|[xh]\.?264`),
+		newTag("AV1", `AV1`),
+		newTag("VC-1", `VC-?1`),
+		newTag("MPEG-2", `MPEG-?2`),
+	}
+	hdrs = []tag{
+		newTag("DV", `DV|DoVi|Dolby[\s.]?Vision`),
+		newTag("HDR10+", `HDR10\+|HDR10Plus`),
+		newTag("HDR10", `HDR10`),
+		newTag("HDR", `HDR`),
+		newTag("HLG", `HLG`),
+	}
+	audios = []tag{
+		newTag("Atmos", `Atmos`),
+		newTag("TrueHD", `TrueHD(?:\d\.\d)?`),
+		newTag("DTS:X", `DTS[:\-]?X`),
+		newTag("DTS-HD MA", `DTS-?HD[\s.]?MA(?:\d\.\d)?`),
+		newTag("DTS", `DTS(?:\d\.\d)?`),
+		newTag("DD+", `DDP(?:\d\.\d)?|DD\+(?:\d\.\d)?|E-?AC-?3`),
+		newTag("DD", `DD(?:\d\.\d)?|AC-?3`),
+		newTag("AAC", `AAC(?:\d\.\d)?`),
+		newTag("FLAC", `FLAC(?:\d\.\d)?`),
+		newTag("LPCM", `L?PCM(?:\d\.\d)?`),
+		newTag("Opus", `Opus`),
+	}
+	editions = []tag{
+		newTag("Extended", `Extended(?:[\s.]Edition|[\s.]Cut)?`),
+		newTag("Director's Cut", `Director'?s[\s.]Cut`),
+		newTag("Unrated", `Unrated`),
+		newTag("Uncut", `Uncut`),
+		newTag("Remastered", `Remastered`),
+		newTag("IMAX", `IMAX`),
+		newTag("Criterion", `Criterion`),
+		newTag("Theatrical", `Theatrical(?:[\s.]Cut)?`),
+	}
+)
+
+// Parse 解析发布名
+func Parse(name string) Info {
+	var info Info
+	s := strings.TrimSpace(extRegexp.ReplaceAllString(strings.TrimSpace(name), ""))
+	// 发布组在结尾的 -GROUP，没有时使用开头的 [GROUP]，如动漫的 [GROUP] Title - 01 [1080p]
+	anime := false
+	if m := leadingGroupRegexp.FindStringSubmatch(s); m != nil {
+		info.Group = m[1]
+		s = s[len(m[0]):]
+		anime = true
+	}
+	if m := groupRegexp.FindStringSubmatchIndex(s); m != nil {
+		if group := s[m[2]:m[3]]; !notGroups[strings.ToUpper(group)] {
+			info.Group = group
+			s = s[:m[0]]
+		}
+	}
+	// 片名在第一个标签之前
+	end := len(s)
+	found := func(loc []int) {
+		if loc != nil && loc[2] < end {
+			end = loc[2]
+		}
+	}
+	info.Resolution = first(s, resolutions, found)
+	if loc := remux.re.FindStringSubmatchIndex(s); loc != nil {
+		info.Remux = true
+		found(loc)
+	}
+	info.Codec = first(s, codecs, found)
+	info.HDR = all(s, hdrs, found)
+	if m := channelsRegexp.FindStringSubmatch(s); m != nil {
+		info.Channels = m[1]
+	}
+	if m := episodeRegexp.FindStringSubmatchIndex(s); m != nil {
+		info.SeasonStart = atoi(s, m, 4)
+		info.SeasonEnd = info.SeasonStart
+		info.EpisodeStart = atoi(s, m, 6)
+		info.EpisodeEnd = max(atoi(s, m, 8), atoi(s, m, 10), info.EpisodeStart)
+		found(m)
+	} else if m := seasonRegexp.FindStringSubmatchIndex(s); m != nil {
+		info.SeasonStart = atoi(s, m, 4)
+		info.SeasonEnd = max(atoi(s, m, 6), info.SeasonStart)
+		found(m)
+	}
+	if m := cnSeasonRegexp.FindStringSubmatchIndex(s); m != nil && info.SeasonStart == 0 {
+		info.SeasonStart = atoi(s, m, 2)
+		info.SeasonEnd = info.SeasonStart
+		found([]int{m[0], m[1], m[0], m[1]})
+	}
+	if m := cnEpisodeRegexp.FindStringSubmatchIndex(s); m != nil && info.EpisodeStart == 0 {
+		info.EpisodeStart = atoi(s, m, 2)
+		info.EpisodeEnd = max(atoi(s, m, 4), info.EpisodeStart)
+		found([]int{m[0], m[1], m[0], m[1]})
+	}
+	// 开头有 [GROUP] 的动漫标题，没有季集标签时取 - 后的绝对集数，4 位数的年份除外
+	if m := absEpisodeRegexp.FindStringSubmatchIndex(s); m != nil && anime && info.EpisodeStart == 0 &&
+		!yearRegexp.MatchString(s[m[2]:m[3]]) {
+		info.EpisodeStart = atoi(s, m, 2)
+		info.EpisodeEnd = max(atoi(s, m, 4), info.EpisodeStart)
+		found([]int{m[0], m[1], m[0], m[1]})
+	}
+	// 年份取标签前的最后一个，片名本身可能是年份，如 2001.A.Space.Odyssey.1968、Blade.Runner.2049.2017；
+	// 标签前没有时取标签后的第一个，如 片名 第1季 2023
+	year, after := -1, -1
+	for i := 0; i < len(s); {
+		m := yearRegexp.FindStringSubmatchIndex(s[i:])
+		if m == nil {
+			break
+		}
+		if pos := i + m[2]; pos > 0 && pos < end {
+			year = pos
+		} else if pos >= end && after < 0 {
+			after = pos
+		}
+		// 边界字符可能属于下一个年份，从分组结尾继续查找
+		i += m[3]
+	}
+	if year > 0 {
+		info.Year, _ = strconv.Atoi(s[year : year+4])
+		end = year
+	} else if after > 0 {
+		info.Year, _ = strconv.Atoi(s[after : after+4])
+	}
+	// 来源、音频和版本可能是片名中的单词，如 Uncut.Gems、Mr.Hollands.Opus、Extended.Family，
+	// 只在年份、季集、分辨率等标签之后匹配；没有这些标签时来源不限位置，音频和版本在来源之后
+	info.Source = first(mask(s, end), sources, found)
+	if end < len(s) {
+		tags := mask(s, end)
+		info.Audio = all(tags, audios, found)
+		info.Edition = first(tags, editions, found)
+	}
+	info.Title = title(s[:end])
+	return info
+}
+
+// first 第一个匹配的标签，规则按优先级排列
+func first(s string, tags []tag, found func(loc []int)) string {
+	for _, t := range tags {
+		if loc := t.re.FindStringSubmatchIndex(s); loc != nil {
+			found(loc)
+			return t.name
+		}
+	}
+	return ""
+}
+
+// all 所有匹配的标签，已匹配的文本不再参与后续规则，避免 HDR10+ 同时匹配 HDR10
+func all(s string, tags []tag, found func(loc []int)) []string {
+	var names []string
+	for _, t := range tags {
+		if loc := t.re.FindStringSubmatchIndex(s); loc != nil {
+			found(loc)
+			names = append(names, t.name)
+			s = s[:loc[2]] + strings.Repeat(" ", loc[3]-loc[2]) + s[loc[3]:]
+		}
+	}
+	return names
+}
+
+// mask 将 end 之前的部分替换为空格，位置不变，end 为 len(s) 时返回 s
+func mask(s string, end int) string {
+	if end >= len(s) {
+		return s
+	}
+	return strings.Repeat(" ", end) + s[end:]
+}
+
+func atoi(s string, m []int, i int) int {
+	if m[i] < 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(s[m[i]:m[i+1]])
+	return n
+}
+
+// title 分隔符替换为空格，去掉首尾的括号和连接符
+func title(s string) string {
+	s = titleSepRegexp.ReplaceAllString(s, " ")
+	return strings.Trim(s, " -([")
+}
@@ -0,0 +1,84 @@
+package release_test
+
+import (
+	"github.com/heibizi/go-btsite/release"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want release.Info
+	}{
+		{"Movie.Name.2023.2160p.UHD.BluRay.REMUX.HDR.HEVC.Atmos-GROUP", release.Info{
+			Title: "Movie Name", Year: 2023, Resolution: "2160p", Source: "BluRay", Remux: true,
+			Codec: "HEVC", HDR: []string{"HDR"}, Audio: []string{"Atmos"}, Group: "GROUP",
+		}},
+		{"Blade.Runner.2049.2017.1080p.BluRay.DTS-HD.MA.7.1.x264-CHD", release.Info{
+			Title: "Blade Runner 2049", Year: 2017, Resolution: "1080p", Source: "BluRay",
+			Codec: "AVC", Audio: []string{"DTS-HD MA"}, Channels: "7.1", Group: "CHD",
+		}},
+		{"The.Last.of.Us.S01E01-E03.2160p.WEB-DL.DDP5.1.DV.HDR10+.H.265-HHWEB", release.Info{
+			Title: "The Last of Us", SeasonStart: 1, SeasonEnd: 1, EpisodeStart: 1, EpisodeEnd: 3,
+			Resolution: "2160p", Source: "WEB-DL", Codec: "HEVC", HDR: []string{"DV", "HDR10+"},
+			Audio: []string{"DD+"}, Channels: "5.1", Group: "HHWEB",
+		}},
+		{"Friends S01-S10 1080p BluRay x265 AAC", release.Info{
+			Title: "Friends", SeasonStart: 1, SeasonEnd: 10, Resolution: "1080p", Source: "BluRay",
+			Codec: "HEVC", Audio: []string{"AAC"},
+		}},
+		{"2001.A.Space.Odyssey.1968.Remastered.720p.WEBRip.AAC2.0.mkv", release.Info{
+			Title: "2001 A Space Odyssey", Year: 1968, Resolution: "720p", Source: "WEBRip",
+			Audio: []string{"AAC"}, Channels: "2.0", Edition: "Remastered",
+		}},
+		{"[Nekomoe kissaten] Frieren - 01 [1080p]", release.Info{
+			Title: "Frieren", EpisodeStart: 1, EpisodeEnd: 1, Resolution: "1080p", Group: "Nekomoe kissaten",
+		}},
+		{"[SubsPlease] One Piece - 1089v2 (1080p) [ABCD1234].mkv", release.Info{
+			Title: "One Piece", EpisodeStart: 1089, EpisodeEnd: 1089, Resolution: "1080p", Group: "SubsPlease",
+		}},
+		{"[VCB-Studio] Frieren - 01-28 [Ma10p_1080p]", release.Info{
+			Title: "Frieren", EpisodeStart: 1, EpisodeEnd: 28, Resolution: "1080p", Group: "VCB-Studio",
+		}},
+		{"[Group] Movie Title - 2023 [1080p]", release.Info{
+			Title: "Movie Title", Year: 2023, Resolution: "1080p", Group: "Group",
+		}},
+		{"流浪地球2 第1季 第01-10集 2023 4K WEB-DL", release.Info{
+			Title: "流浪地球2", Year: 2023, SeasonStart: 1, SeasonEnd: 1, EpisodeStart: 1, EpisodeEnd: 10,
+			Resolution: "2160p", Source: "WEB-DL",
+		}},
+		{"Aliens.1986.Directors.Cut.1080p.BluRay.TrueHD.Atmos.7.1-FGT", release.Info{
+			Title: "Aliens", Year: 1986, Resolution: "1080p", Source: "BluRay",
+			Audio: []string{"Atmos", "TrueHD"}, Channels: "7.1", Edition: "Director's Cut", Group: "FGT",
+		}},
+		// 来源、音频和版本的单词出现在片名中
+		{"Uncut.Gems.2019.1080p.BluRay.x264-GROUP", release.Info{
+			Title: "Uncut Gems", Year: 2019, Resolution: "1080p", Source: "BluRay", Codec: "AVC", Group: "GROUP",
+		}},
+		{"Mr.Hollands.Opus.1995.1080p.WEB-DL.DD5.1.H.264-GROUP", release.Info{
+			Title: "Mr Hollands Opus", Year: 1995, Resolution: "1080p", Source: "WEB-DL", Codec: "AVC",
+			Audio: []string{"DD"}, Channels: "5.1", Group: "GROUP",
+		}},
+		{"Extended.Family.S01E01.1080p.WEB.h264-GROUP", release.Info{
+			Title: "Extended Family", SeasonStart: 1, SeasonEnd: 1, EpisodeStart: 1, EpisodeEnd: 1,
+			Resolution: "1080p", Source: "WEB-DL", Codec: "AVC", Group: "GROUP",
+		}},
+		{"Charlottes.Web.2006.720p.BluRay.x264-GROUP", release.Info{
+			Title: "Charlottes Web", Year: 2006, Resolution: "720p", Source: "BluRay", Codec: "AVC", Group: "GROUP",
+		}},
+		{"The.BD.Files.2020.2160p.WEB-DL.DDP5.1.HEVC-GROUP", release.Info{
+			Title: "The BD Files", Year: 2020, Resolution: "2160p", Source: "WEB-DL", Codec: "HEVC",
+			Audio: []string{"DD+"}, Channels: "5.1", Group: "GROUP",
+		}},
+		// 没有年份、季集、分辨率时来源作为锚点
+		{"Movie.Name.BluRay.DTS-GROUP", release.Info{
+			Title: "Movie Name", Source: "BluRay", Audio: []string{"DTS"}, Group: "GROUP",
+		}},
+	}
+	for _, tt := range tests {
+		if got := release.Parse(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q)\ngot  %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
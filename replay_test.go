@@ -0,0 +1,335 @@
+package btsite_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-btsite/btsitetest"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var update = flag.Bool("update", false, "更新回放测试的 golden 文件")
+
+type (
+	// replayParams 回放测试参数，录制时从环境变量 GO_BTSITE_KEYWORD、GO_BTSITE_TORRENT_ID 生成
+	replayParams struct {
+		Keyword   string `json:"keyword"`
+		TorrentID string `json:"torrent_id"`
+		UserID    string `json:"user_id,omitempty"` // 替换后的用户 id，回放时作为 Site.UserId
+	}
+	// replayCase 回放测试用例，用例按顺序执行，录制和回放的请求顺序一致
+	replayCase struct {
+		name string
+		call func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error)
+	}
+)
+
+// 录制时替换的用户名，数字用户 id 替换为 replayUserID，其他用户 id 替换为 replayUserName
+const (
+	replayUserName = "btsite"
+	replayUserID   = "10000"
+)
+
+var replayCases = []replayCase{
+	{"user_basic_info", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		return c.UserBasicInfo(ctx)
+	}},
+	{"user_details", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		return c.UserDetails(ctx)
+	}},
+	{"search", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		return c.Search(ctx, btsite.SearchParams{Keyword: params.Keyword, MediaType: btsite.Movie})
+	}},
+	{"seeding_statistics", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		return c.SeedingStatistics(ctx)
+	}},
+	{"my_hr", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		return c.MyHr(ctx)
+	}},
+	{"unread_messages", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		return c.UnreadMessages(ctx, false)
+	}},
+	{"latest_notice", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		return c.LatestNotice(ctx)
+	}},
+	{"details", func(ctx context.Context, c btsite.ClientContext, params replayParams) (any, error) {
+		if params.TorrentID == "" {
+			return nil, nil
+		}
+		return c.Details(ctx, params.TorrentID)
+	}},
+}
+
+// TestReplay 回放 testdata/replay/<站点唯一标识> 下录制的请求，与 golden 文件比较结果，站点配置为同目录下的 configs，
+// 公共配置优先使用 configs/commons。没有录制的站点时测试失败。返回 ErrUnsupported 的用例没有 golden 文件。
+// 设置 GO_BTSITE_RECORD=1 时使用 GO_BTSITE_* 环境变量配置的站点录制，-update 更新 golden 文件。
+func TestReplay(t *testing.T) {
+	root := filepath.Join("testdata", "replay")
+	mode := btsitetest.ModeFromEnv()
+	var codes []string
+	if mode == btsitetest.ModeRecord {
+		codes = append(codes, os.Getenv("GO_BTSITE_CODE"))
+	} else {
+		entries, err := os.ReadDir(root)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				codes = append(codes, entry.Name())
+			}
+		}
+	}
+	if len(codes) == 0 || codes[0] == "" {
+		t.Fatalf("%s 下没有录制的站点", root)
+	}
+	for _, code := range codes {
+		t.Run(code, func(t *testing.T) {
+			dir := filepath.Join(root, code)
+			if mode == btsitetest.ModeRecord {
+				recordSite(t, dir, code)
+			}
+			replaySite(t, dir, code, mode == btsitetest.ModeRecord || *update)
+		})
+	}
+}
+
+// recordSite 请求真实站点录制 cassette，替换用户名和用户 id，并复制站点配置和公共配置到 dir/configs
+func recordSite(t *testing.T, dir string, code string) {
+	sc, err := btsite.SiteHelper.GetConfigByCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyReplayConfigs(t, dir, sc)
+	params := replayParams{
+		Keyword:   os.Getenv("GO_BTSITE_KEYWORD"),
+		TorrentID: os.Getenv("GO_BTSITE_TORRENT_ID"),
+	}
+	recorded := t.Run("record", func(t *testing.T) {
+		site := liveSite()
+		site.Domain, site.Api = sc.Domain, sc.Api
+		cassette := btsitetest.Start(t, btsitetest.ModeRecord, filepath.Join(dir, "cassette.json"), site)
+		c, err := btsite.NewClientContext(site)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		info, err := c.UserBasicInfo(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{site.UserId, info.ID} {
+			cassette.Redact(id, redactedUserID(id))
+		}
+		cassette.Redact(info.Name, replayUserName)
+		// 未配置用户 id 时由客户端获取，回放时同样不配置
+		if site.UserId != "" {
+			params.UserID = redactedUserID(site.UserId)
+		}
+		for _, rc := range replayCases {
+			_, _ = rc.call(ctx, c, params)
+		}
+	})
+	if !recorded {
+		t.FailNow()
+	}
+	writeJSON(t, filepath.Join(dir, "params.json"), params)
+}
+
+// redactedUserID 用户 id 替换后的值，UNIT3D 等站点使用用户名作为用户 id
+func redactedUserID(id string) string {
+	if _, err := strconv.Atoi(id); err == nil {
+		return replayUserID
+	}
+	return replayUserName
+}
+
+// copyReplayConfigs 从 GO_BTSITE_CONFIGS_PATH 复制站点配置，以及 configs/commons 中没有的公共配置
+func copyReplayConfigs(t *testing.T, dir string, sc btsite.Config) {
+	configs := os.Getenv("GO_BTSITE_CONFIGS_PATH")
+	files, err := filepath.Glob(filepath.Join(configs, "sites", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := false
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var id struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(b, &id) == nil && id.ID == sc.ID {
+			writeFile(t, filepath.Join(dir, "configs", "sites", sc.ID+".json"), b)
+			copied = true
+		}
+	}
+	if !copied {
+		t.Fatalf("%s/sites 下没有站点配置: %s", configs, sc.ID)
+	}
+	for _, schema := range []string{sc.Schema, sc.ReuseSchema} {
+		if schema == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join("configs", "commons", schema+".json")); err == nil {
+			continue
+		}
+		if b, err := os.ReadFile(filepath.Join(configs, "commons", schema+".json")); err == nil {
+			writeFile(t, filepath.Join(dir, "configs", "commons", schema+".json"), b)
+		}
+	}
+}
+
+// replaySite 回放 dir 下录制的请求，golden 为 true 时更新 golden 文件
+func replaySite(t *testing.T, dir string, code string, golden bool) {
+	registry := replayRegistry(t, dir)
+	var params replayParams
+	readJSON(t, filepath.Join(dir, "params.json"), &params)
+	// 录制时 API 令牌被替换为 btsitetest.Redacted
+	site := &btsite.Site{Code: code, Name: code, UserId: params.UserID, ApiToken: btsitetest.Redacted}
+	btsitetest.Start(t, btsitetest.ModeReplay, filepath.Join(dir, "cassette.json"), site)
+	c, err := registry.NewClientContext(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rc := range replayCases {
+		result, err := rc.call(context.Background(), c, params)
+		path := filepath.Join(dir, rc.name+".golden.json")
+		if errors.Is(err, btsite.ErrUnsupported) {
+			// 架构不支持的功能没有 golden 文件
+			if golden {
+				if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					t.Fatal(err)
+				}
+			} else if _, err := os.Stat(path); err == nil {
+				t.Errorf("%s 返回 ErrUnsupported，但存在 golden 文件", rc.name)
+			}
+			continue
+		}
+		got := replayOutput(t, site, result, err)
+		if golden {
+			writeFile(t, path, got)
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s 没有 golden 文件，结果: %s", rc.name, got)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s 与 golden 文件不一致\ngot:\n%s\nwant:\n%s", rc.name, got, want)
+		}
+	}
+}
+
+// replayRegistry 加载 dir/configs 下的配置，configs/commons 下的公共配置优先，测试的是当前的适配
+func replayRegistry(t *testing.T, dir string) *btsite.Registry {
+	fsys := fstest.MapFS{"commons": {Mode: fs.ModeDir}}
+	root := filepath.Join(dir, "configs")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		fsys[filepath.ToSlash(rel)] = &fstest.MapFile{Data: b}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commons, err := filepath.Glob(filepath.Join("configs", "commons", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range commons {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys["commons/"+filepath.Base(path)] = &fstest.MapFile{Data: b}
+	}
+	conf, err := btsite.LoadConfig(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return btsite.NewRegistry(conf)
+}
+
+// replayOutput 结果和异常转为 JSON，本地服务的地址替换为 {domain}、{api}，与端口无关，
+// SiteError 的原始异常来自依赖的库，不写入 golden 文件
+func replayOutput(t *testing.T, site *btsite.Site, result any, err error) []byte {
+	t.Helper()
+	output := struct {
+		Result any    `json:"result"`
+		Error  string `json:"error,omitempty"`
+	}{Result: result}
+	if err != nil {
+		output.Error = err.Error()
+		var se *btsite.SiteError
+		if errors.As(err, &se) && se.Err != nil {
+			output.Error = strings.TrimSuffix(output.Error, ": "+se.Err.Error())
+		}
+	}
+	b, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.ReplaceAll(string(b), strings.TrimSuffix(site.Domain, "/"), "{domain}")
+	if site.Api != "" {
+		s = strings.ReplaceAll(s, strings.TrimSuffix(site.Api, "/"), "{api}")
+	}
+	return []byte(s + "\n")
+}
+
+func readJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, append(b, '\n'))
+}
+
+// writeFile 写入文件，目录不存在时自动创建
+func writeFile(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,92 @@
+package btsite
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 10 * time.Second
+)
+
+type (
+	// RetryPolicy 重试策略，指数退避加随机抖动
+	RetryPolicy struct {
+		MaxAttempts        int   `mapstructure:"max_attempts"`         // 最大请求次数，包括首次请求，<= 1 时不重试
+		BaseDelayMs        int   `mapstructure:"base_delay_ms"`        // 首次重试的等待毫秒数，默认 500
+		MaxDelayMs         int   `mapstructure:"max_delay_ms"`         // 最大等待毫秒数，默认 10000
+		StatusCodes        []int `mapstructure:"status_codes"`         // 需要重试的状态码，默认 429、500、502、503、504
+		AllowNonIdempotent bool  `mapstructure:"allow_non_idempotent"` // 是否允许重试签到等非幂等请求
+	}
+	// RetryConfig 站点重试配置
+	RetryConfig struct {
+		RetryPolicy `mapstructure:",squash"`
+		Requests    map[string]RetryPolicy `mapstructure:"requests"` // 按请求 id 配置，优先于站点配置
+	}
+)
+
+var defaultRetryStatusCodes = []int{429, 500, 502, 503, 504}
+
+// 非幂等请求，默认不重试
+var nonIdempotentRequests = map[requestId]bool{
+	requestIdSignIn:       true,
+	requestIdMarkAsRead:   true,
+	requestIdMTGenDLToken: true,
+}
+
+// policyFor 获取请求的重试策略
+func (rc RetryConfig) policyFor(reqId requestId) RetryPolicy {
+	if policy, ok := rc.Requests[string(reqId)]; ok {
+		return policy
+	}
+	return rc.RetryPolicy
+}
+
+// retryable 判断请求异常是否可以重试，没有请求 id 的自定义请求无法判断是否幂等，不重试
+func (p RetryPolicy) retryable(reqId requestId, err error) bool {
+	if reqId == "" || nonIdempotentRequests[reqId] && !p.AllowNonIdempotent {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var se *SiteError
+	// 人机验证需要人工处理，重试也无法通过
+	if !errors.As(err, &se) || errors.Is(se.Kind, ErrChallenge) {
+		return false
+	}
+	codes := p.StatusCodes
+	if codes == nil {
+		codes = defaultRetryStatusCodes
+	}
+	for _, code := range codes {
+		if se.StatusCode == code {
+			return true
+		}
+	}
+	// 状态码未知时，根据异常类型判断
+	return se.StatusCode == 0 && (errors.Is(se.Kind, ErrSiteUnavailable) || errors.Is(se.Kind, ErrRateLimited))
+}
+
+// backoff 第 attempt 次请求失败后的等待时间，在指数退避时间的 [1/2, 1] 之间随机
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	base, maxDelay := defaultRetryBaseDelay, defaultRetryMaxDelay
+	if p.BaseDelayMs > 0 {
+		base = time.Duration(p.BaseDelayMs) * time.Millisecond
+	}
+	if p.MaxDelayMs > 0 {
+		maxDelay = time.Duration(p.MaxDelayMs) * time.Millisecond
+	}
+	d := base
+	for i := 1; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int64N(half+1))
+}
@@ -0,0 +1,76 @@
+package btsite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3}
+	cases := []struct {
+		name  string
+		reqId requestId
+		err   error
+		want  bool
+	}{
+		{"bad gateway", requestIdSearch, &SiteError{StatusCode: 502, Err: errors.New("502")}, true},
+		{"not found", requestIdSearch, &SiteError{StatusCode: 404, Err: errors.New("404")}, false},
+		{"connection reset", requestIdSearch, &SiteError{Kind: ErrSiteUnavailable, Err: errors.New("reset")}, true},
+		{"sign in", requestIdSignIn, &SiteError{StatusCode: 502, Err: errors.New("502")}, false},
+		{"canceled", requestIdSearch, &SiteError{StatusCode: 502, Err: context.Canceled}, false},
+		{"plain error", requestIdSearch, errors.New("plain"), false},
+		{"custom request", "", &SiteError{StatusCode: 502, Err: errors.New("502")}, false},
+	}
+	for _, c := range cases {
+		if got := p.retryable(c.reqId, c.err); got != c.want {
+			t.Errorf("%s: retryable() = %v, want %v", c.name, got, c.want)
+		}
+	}
+	p.AllowNonIdempotent = true
+	if !p.retryable(requestIdSignIn, &SiteError{StatusCode: 502, Err: errors.New("502")}) {
+		t.Error("sign in with AllowNonIdempotent: retryable() = false, want true")
+	}
+}
+
+func TestRetryConfigPolicyFor(t *testing.T) {
+	rc := RetryConfig{
+		RetryPolicy: RetryPolicy{MaxAttempts: 2},
+		Requests:    map[string]RetryPolicy{"search": {MaxAttempts: 5}},
+	}
+	if got := rc.policyFor(requestIdSearch).MaxAttempts; got != 5 {
+		t.Errorf("policyFor(search).MaxAttempts = %d, want 5", got)
+	}
+	if got := rc.policyFor(requestIdDetails).MaxAttempts; got != 2 {
+		t.Errorf("policyFor(details).MaxAttempts = %d, want 2", got)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelayMs: 100, MaxDelayMs: 300}
+	for attempt, want := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 10: 300} {
+		want *= time.Millisecond
+		got := p.backoff(attempt)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %v, want [%v, %v]", attempt, got, want/2, want)
+		}
+	}
+}
+
+func TestNewOutput(t *testing.T) {
+	messages := []Message{{ID: "1"}}
+	out := newOutput(&messages)
+	if got := *out.(*[]Message); got != nil {
+		t.Errorf("newOutput() = %v, want nil", got)
+	}
+	*out.(*[]Message) = []Message{{ID: "2"}}
+	setOutput(&messages, out)
+	if len(messages) != 1 || messages[0].ID != "2" {
+		t.Errorf("messages = %v, want [2]", messages)
+	}
+	ubi := UserBasicInfo{Name: "user"}
+	if got := *newOutput(&ubi).(*UserBasicInfo); got.Name != "" {
+		t.Errorf("newOutput() = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,162 @@
+package btsite
+
+import (
+	"context"
+	"fmt"
+	"github.com/heibizi/go-btsite/release"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	imdbIDRegexp   = regexp.MustCompile(`tt\d{5,}`)
+	doubanIDRegexp = regexp.MustCompile(`(?:subject/)?(\d{5,})`)
+)
+
+// 促销状态对应的下载、上传系数
+var promotionFactors = map[Promotion][2]float64{
+	PromotionNormal:  {1, 1},
+	PromotionFree:    {0, 1},
+	Promotion2X:      {1, 2},
+	Promotion2XFree:  {0, 2},
+	PromotionHalf:    {0.5, 1},
+	Promotion2XHalf:  {0.5, 2},
+	PromotionThirty:  {0.3, 1},
+	PromotionSeventy: {0.7, 1},
+}
+
+// localFilter 站点不支持、需要在本地过滤的搜索条件，体积总是在本地过滤
+type localFilter struct {
+	promotion bool // 按下载、上传系数过滤促销状态
+	imdb      bool // 按 IMDb 编号过滤
+	douban    bool // 按豆瓣编号过滤
+}
+
+// searchResult 过滤站点不支持的搜索条件并解析标题的发布名，各架构的 Search 返回前调用，
+// 过滤前的种子记录到 SearchCursor 的 ctx 中
+func searchResult(ctx context.Context, searchParams SearchParams, torrents []SearchTorrent, local localFilter) []SearchTorrent {
+	if page, ok := ctx.Value(searchPageKey{}).(*searchPage); ok {
+		page.reported = true
+		for _, torrent := range torrents {
+			page.keys = append(page.keys, searchKey(torrent))
+		}
+	}
+	torrents = filterTorrents(searchParams, torrents, local)
+	for i := range torrents {
+		torrents[i].Parsed = parseRelease(torrents[i].Title)
+	}
+	return torrents
+}
+
+// searchCategories 搜索的分类 id，Categories 不为空时使用 Categories，否则为 MediaType 对应的站点分类
+func searchCategories(sc Config, searchParams SearchParams) []string {
+	if len(searchParams.Categories) > 0 {
+		return searchParams.Categories
+	}
+	var cats []AdaptMediaCat
+	switch searchParams.MediaType {
+	case Movie:
+		cats = sc.Categories.Movie
+	case Tv:
+		cats = sc.Categories.TV
+	}
+	var ids []string
+	for _, cat := range cats {
+		ids = append(ids, cat.ID)
+	}
+	return ids
+}
+
+// parseRelease 解析发布名，标题为空时返回 nil
+func parseRelease(title string) *release.Info {
+	if strings.TrimSpace(title) == "" {
+		return nil
+	}
+	info := release.Parse(title)
+	return &info
+}
+
+// idSearchError 没有关键字，且请求的 IMDb、豆瓣编号都只能在本地过滤时返回 ErrUnsupportedSearch，
+// 避免返回按最新种子过滤的结果
+func idSearchError(site *Site, searchParams SearchParams, local localFilter) error {
+	if len(searchParams.Keyword) > 0 {
+		return nil
+	}
+	imdb := len(searchParams.IMDbID) > 0
+	douban := len(searchParams.DoubanID) > 0
+	if (imdb && !local.imdb) || (douban && !local.douban) || (!imdb && !douban) {
+		return nil
+	}
+	return newError(site, ErrUnsupportedSearch, "不支持按 IMDb 或豆瓣编号搜索，请同时指定关键字")
+}
+
+// filterTorrents 过滤站点不支持的搜索条件，local 为需要在本地过滤的条件。
+// 按编号过滤时，没有对应编号的种子也会被过滤
+func filterTorrents(searchParams SearchParams, torrents []SearchTorrent, local localFilter) []SearchTorrent {
+	factors, filterPromotion := promotionFactors[searchParams.Promotion]
+	filterPromotion = filterPromotion && local.promotion
+	imdbID := ""
+	if local.imdb {
+		imdbID = normalizeIMDbID(searchParams.IMDbID)
+	}
+	doubanID := ""
+	if local.douban {
+		doubanID = normalizeDoubanID(searchParams.DoubanID)
+	}
+	if searchParams.MinSize <= 0 && searchParams.MaxSize <= 0 && !filterPromotion && imdbID == "" && doubanID == "" {
+		return torrents
+	}
+	var filtered []SearchTorrent
+	for _, torrent := range torrents {
+		if searchParams.MinSize > 0 && torrent.Size < searchParams.MinSize {
+			continue
+		}
+		if searchParams.MaxSize > 0 && torrent.Size > searchParams.MaxSize {
+			continue
+		}
+		if filterPromotion && (torrent.DownloadVolumeFactor != factors[0] || torrent.UploadVolumeFactor != factors[1]) {
+			continue
+		}
+		if imdbID != "" && torrent.IMDbID != imdbID {
+			continue
+		}
+		if doubanID != "" && torrent.DoubanID != doubanID {
+			continue
+		}
+		filtered = append(filtered, torrent)
+	}
+	return filtered
+}
+
+// normalizeIMDbID 从编号或链接中获取 IMDb 编号，纯数字时补全 tt 前缀，如 https://www.imdb.com/title/tt0133093/
+func normalizeIMDbID(s string) string {
+	s = strings.TrimSpace(s)
+	if id := imdbIDRegexp.FindString(s); id != "" {
+		return id
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return fmt.Sprintf("tt%07d", n)
+	}
+	return ""
+}
+
+// normalizeDoubanID 从编号或链接中获取豆瓣编号，如 https://movie.douban.com/subject/1291843/
+func normalizeDoubanID(s string) string {
+	if m := doubanIDRegexp.FindStringSubmatch(strings.TrimSpace(s)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// joinURLs 将相对地址转为完整地址，空地址或拼接失败时原样返回
+func joinURLs(base string, ref string) string {
+	if ref == "" || base == "" {
+		return ref
+	}
+	u, err := JoinURL(base, ref)
+	if err != nil {
+		return ref
+	}
+	return u
+}
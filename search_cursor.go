@@ -0,0 +1,143 @@
+package btsite
+
+import (
+	"context"
+	"fmt"
+)
+
+// SearchCursor 自动翻页的搜索游标，用法同 bufio.Scanner：
+//
+//	cursor := btsite.SearchAll(ctx, c, params, 500)
+//	for cursor.Next() {
+//		torrent := cursor.Torrent()
+//	}
+//	if err := cursor.Err(); err != nil {
+//	}
+//
+// 每页通过 ClientContext.Search 请求，与其他请求共享站点的限流配置。
+// 站点返回空页、整页都是已请求过的种子（站点不支持翻页时）、达到 limit、ctx 结束或搜索异常时停止，
+// 按本地过滤前站点返回的种子判断，本地过滤后为空的页会继续请求下一页，自定义客户端按 Search 的返回值判断。
+// 单次最多请求 maxSearchPages 页，超过时停止并返回异常。
+// 翻页期间站点发布新种子可能导致种子出现在相邻两页，游标按 ID 去重，没有 ID 的种子按标题、详情页和下载链接去重。不支持并发使用。
+type SearchCursor struct {
+	ctx    context.Context
+	c      ClientContext
+	params SearchParams
+	limit  int
+
+	page     []SearchTorrent
+	torrent  SearchTorrent
+	seen     map[string]bool // 已返回的种子
+	siteSeen map[string]bool // 站点返回过的种子，包括本地过滤掉的
+	pages    int             // 已请求的页数
+	count    int
+	done     bool
+	err      error
+}
+
+// maxSearchPages SearchCursor 最多请求的页数，避免站点一直返回新种子时无限翻页
+const maxSearchPages = 100
+
+type (
+	// searchPageKey ctx 中记录本地过滤前站点返回的种子，由 searchResult 写入
+	searchPageKey struct{}
+	// searchPage 本地过滤前站点返回的种子，元素为 searchKey
+	searchPage struct {
+		reported bool
+		keys     []string
+	}
+)
+
+// SearchAll 从 params.Page 开始逐页搜索，limit <= 0 时不限制数量
+func SearchAll(ctx context.Context, c ClientContext, params SearchParams, limit int) *SearchCursor {
+	return &SearchCursor{
+		ctx:      ctx,
+		c:        c,
+		params:   params,
+		limit:    limit,
+		seen:     make(map[string]bool),
+		siteSeen: make(map[string]bool),
+	}
+}
+
+// Next 移动到下一个种子，没有更多种子或异常时返回 false
+func (sc *SearchCursor) Next() bool {
+	if sc.done {
+		return false
+	}
+	if sc.limit > 0 && sc.count >= sc.limit {
+		sc.done = true
+		return false
+	}
+	for len(sc.page) == 0 {
+		if !sc.fetch() {
+			sc.done = true
+			return false
+		}
+	}
+	sc.torrent, sc.page = sc.page[0], sc.page[1:]
+	sc.count++
+	return true
+}
+
+// fetch 请求下一页，过滤已返回的种子，站点没有返回新种子时返回 false
+func (sc *SearchCursor) fetch() bool {
+	if err := sc.ctx.Err(); err != nil {
+		sc.err = err
+		return false
+	}
+	if sc.pages >= maxSearchPages {
+		sc.err = fmt.Errorf("超过最大页数 %d", maxSearchPages)
+		return false
+	}
+	sc.pages++
+	page := &searchPage{}
+	torrents, err := sc.c.Search(context.WithValue(sc.ctx, searchPageKey{}, page), sc.params)
+	if err != nil {
+		sc.err = err
+		return false
+	}
+	sc.params.Page++
+	if !page.reported {
+		for _, torrent := range torrents {
+			page.keys = append(page.keys, searchKey(torrent))
+		}
+	}
+	more := false
+	for _, key := range page.keys {
+		more = more || !sc.siteSeen[key]
+		sc.siteSeen[key] = true
+	}
+	for _, torrent := range torrents {
+		key := searchKey(torrent)
+		if sc.seen[key] {
+			continue
+		}
+		sc.seen[key] = true
+		sc.page = append(sc.page, torrent)
+	}
+	return more
+}
+
+// searchKey 种子的去重键，优先使用 ID，没有 ID 时使用标题、详情页和下载链接
+func searchKey(torrent SearchTorrent) string {
+	if torrent.ID != "" {
+		return "id:" + torrent.ID
+	}
+	return "row:" + torrent.Title + "\x00" + torrent.PageURL + "\x00" + torrent.Enclosure
+}
+
+// Torrent 当前种子，Next 返回 true 后有效
+func (sc *SearchCursor) Torrent() SearchTorrent {
+	return sc.torrent
+}
+
+// Err 停止的原因，正常结束时为空
+func (sc *SearchCursor) Err() error {
+	return sc.err
+}
+
+// Count 已返回的种子数量
+func (sc *SearchCursor) Count() int {
+	return sc.count
+}
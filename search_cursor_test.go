@@ -0,0 +1,142 @@
+package btsite_test
+
+import (
+	"context"
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// pagedClient 按页码返回 pages 的搜索客户端，超出页数返回空页
+type pagedClient struct {
+	*btsite.NexusPHPClient
+	pages [][]string
+	err   error
+	calls []int
+}
+
+func (c *pagedClient) Search(ctx context.Context, searchParams btsite.SearchParams) ([]btsite.SearchTorrent, error) {
+	c.calls = append(c.calls, searchParams.Page)
+	if searchParams.Page >= len(c.pages) {
+		return nil, c.err
+	}
+	var torrents []btsite.SearchTorrent
+	for _, id := range c.pages[searchParams.Page] {
+		torrents = append(torrents, btsite.SearchTorrent{ID: id})
+	}
+	return torrents, nil
+}
+
+func collect(cursor *btsite.SearchCursor) []string {
+	var ids []string
+	for cursor.Next() {
+		ids = append(ids, cursor.Torrent().ID)
+	}
+	return ids
+}
+
+func TestSearchAll(t *testing.T) {
+	ctx := context.Background()
+	c := &pagedClient{pages: [][]string{{"1", "2"}, {"2", "3"}, {"4"}}}
+	cursor := btsite.SearchAll(ctx, c, btsite.SearchParams{Keyword: "x"}, 0)
+	if got := collect(cursor); !reflect.DeepEqual(got, []string{"1", "2", "3", "4"}) || cursor.Err() != nil {
+		t.Errorf("SearchAll() = %v, %v", got, cursor.Err())
+	}
+	if !reflect.DeepEqual(c.calls, []int{0, 1, 2, 3}) {
+		t.Errorf("pages requested = %v, want [0 1 2 3]", c.calls)
+	}
+
+	c = &pagedClient{pages: [][]string{{"1", "2"}, {"3", "4"}, {"5"}}}
+	cursor = btsite.SearchAll(ctx, c, btsite.SearchParams{Page: 1}, 1)
+	if got := collect(cursor); !reflect.DeepEqual(got, []string{"3"}) || cursor.Count() != 1 {
+		t.Errorf("SearchAll(limit 1) = %v", got)
+	}
+	if !reflect.DeepEqual(c.calls, []int{1}) {
+		t.Errorf("pages requested = %v, want [1]", c.calls)
+	}
+}
+
+func TestSearchAllStops(t *testing.T) {
+	ctx := context.Background()
+	// 站点忽略页码，每页都相同
+	c := &pagedClient{pages: [][]string{{"1"}, {"1"}, {"1"}}}
+	if got := collect(btsite.SearchAll(ctx, c, btsite.SearchParams{}, 0)); !reflect.DeepEqual(got, []string{"1"}) || len(c.calls) != 2 {
+		t.Errorf("SearchAll(same pages) = %v, calls %v", got, c.calls)
+	}
+
+	errSearch := errors.New("search failed")
+	c = &pagedClient{pages: [][]string{{"1"}}, err: errSearch}
+	cursor := btsite.SearchAll(ctx, c, btsite.SearchParams{}, 0)
+	if got := collect(cursor); !reflect.DeepEqual(got, []string{"1"}) || !errors.Is(cursor.Err(), errSearch) {
+		t.Errorf("SearchAll(error) = %v, %v", got, cursor.Err())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	c = &pagedClient{pages: [][]string{{"1"}}}
+	cursor = btsite.SearchAll(ctx, c, btsite.SearchParams{}, 0)
+	if cursor.Next() || !errors.Is(cursor.Err(), context.Canceled) || len(c.calls) != 0 {
+		t.Errorf("SearchAll(canceled) err = %v, calls %v", cursor.Err(), c.calls)
+	}
+}
+
+// rowsClient 每页返回 rows(page) 的搜索客户端
+type rowsClient struct {
+	*btsite.NexusPHPClient
+	rows  func(page int) []btsite.SearchTorrent
+	calls int
+}
+
+func (c *rowsClient) Search(ctx context.Context, searchParams btsite.SearchParams) ([]btsite.SearchTorrent, error) {
+	c.calls++
+	return c.rows(searchParams.Page), nil
+}
+
+func TestSearchAllPageGuard(t *testing.T) {
+	ctx := context.Background()
+	// 站点忽略页码，每页都是相同的没有 ID 的种子
+	c := &rowsClient{rows: func(page int) []btsite.SearchTorrent {
+		return []btsite.SearchTorrent{{Title: "A", Enclosure: "dl/a"}, {Title: "B", Enclosure: "dl/b"}}
+	}}
+	cursor := btsite.SearchAll(ctx, c, btsite.SearchParams{}, 0)
+	if got := collect(cursor); len(got) != 2 || cursor.Err() != nil || c.calls != 2 {
+		t.Errorf("SearchAll(same id-less pages) = %d torrents, %v, calls %d", len(got), cursor.Err(), c.calls)
+	}
+
+	// 每页都返回新种子时，超过最大页数停止
+	c = &rowsClient{rows: func(page int) []btsite.SearchTorrent {
+		return []btsite.SearchTorrent{{Title: strconv.Itoa(page)}}
+	}}
+	cursor = btsite.SearchAll(ctx, c, btsite.SearchParams{}, 0)
+	if got := collect(cursor); len(got) != 100 || cursor.Err() == nil || c.calls != 100 {
+		t.Errorf("SearchAll(endless) = %d torrents, %v, calls %d", len(got), cursor.Err(), c.calls)
+	}
+}
+
+// TestSearchAllLocalFilter 本地过滤后为空的页不停止，站点返回空页时停止
+func TestSearchAllLocalFilter(t *testing.T) {
+	var pages []string
+	c, _ := newFixtureClient(t, "UNIT3D", "unit3d", func(r *http.Request) string {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		switch page {
+		case "1":
+			return "filter.json"
+		case "2":
+			return "filter_page2.json"
+		}
+		return "filter_empty.json"
+	}, func(site *btsite.Site) {
+		site.ApiToken = "fixture-token"
+	})
+	cursor := btsite.SearchAll(context.Background(), c, btsite.SearchParams{Keyword: "The Matrix", MinSize: 30 << 30}, 0)
+	if got := collect(cursor); !reflect.DeepEqual(got, []string{"5003"}) || cursor.Err() != nil {
+		t.Errorf("SearchAll() = %v, %v", got, cursor.Err())
+	}
+	if !reflect.DeepEqual(pages, []string{"1", "2", "3"}) {
+		t.Errorf("pages requested = %v, want [1 2 3]", pages)
+	}
+}
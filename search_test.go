@@ -0,0 +1,155 @@
+package btsite
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestNPSearchParams(t *testing.T) {
+	var sc Config
+	sc.Categories.Movie = []AdaptMediaCat{{ID: "cat401"}}
+	sc.Categories.TV = []AdaptMediaCat{{ID: "cat402"}}
+	cases := []struct {
+		name        string
+		params      SearchParams
+		want        url.Values
+		wantKeyword string
+	}{
+		{"latest", SearchParams{Page: 1}, url.Values{"page": {"1"}}, ""},
+		{"keyword", SearchParams{Keyword: "Matrix", MediaType: Movie}, url.Values{
+			"page": {"0"}, "search_mode": {"0"}, "notnewword": {"1"}, "cat401": {"1"},
+		}, "Matrix"},
+		{"imdb", SearchParams{IMDbID: "tt0133093", Categories: []string{"cat403"}}, url.Values{
+			"page": {"0"}, "search_mode": {"0"}, "notnewword": {"1"}, "search_area": {"4"}, "cat403": {"1"},
+		}, "tt0133093"},
+		{"filters", SearchParams{Promotion: PromotionFree, IncludeDead: true, SortBy: SortBySize, SortOrder: SortAsc}, url.Values{
+			"page": {"0"}, "spstate": {"2"}, "incldead": {"0"}, "sort": {"5"}, "type": {"asc"},
+		}, ""},
+		{"unsupported promotion", SearchParams{Promotion: PromotionSeventy, SortBy: SortByTime}, url.Values{
+			"page": {"0"}, "sort": {"4"}, "type": {"desc"},
+		}, ""},
+	}
+	for _, c := range cases {
+		params, keyword := npSearchParams(sc, c.params)
+		if !reflect.DeepEqual(params, c.want) || keyword != c.wantKeyword {
+			t.Errorf("%s: npSearchParams() = %v, %q, want %v, %q", c.name, params, keyword, c.want, c.wantKeyword)
+		}
+	}
+}
+
+func TestMTSearchBody(t *testing.T) {
+	body := mtSearchBody(SearchParams{
+		MediaType:   Tv,
+		IMDbID:      "tt0133093",
+		Categories:  []string{"402"},
+		Promotion:   Promotion2XFree,
+		IncludeDead: true,
+		SortBy:      SortBySeeders,
+	})
+	want := map[string]any{
+		"mode":          "tvshow",
+		"pageNumber":    1,
+		"pageSize":      100,
+		"imdb":          "tt0133093",
+		"categories":    []string{"402"},
+		"discount":      "_2X_FREE",
+		"sortField":     "seeders",
+		"sortDirection": "DESC",
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("mtSearchBody() = %v, want %v", body, want)
+	}
+}
+
+func TestFilterTorrents(t *testing.T) {
+	torrents := []SearchTorrent{
+		{ID: "1", Size: 100, DownloadVolumeFactor: 0, UploadVolumeFactor: 1},
+		{ID: "2", Size: 200, DownloadVolumeFactor: 0.7, UploadVolumeFactor: 1},
+		{ID: "3", Size: 300, DownloadVolumeFactor: 1, UploadVolumeFactor: 1},
+	}
+	ids := func(torrents []SearchTorrent) []string {
+		var ids []string
+		for _, torrent := range torrents {
+			ids = append(ids, torrent.ID)
+		}
+		return ids
+	}
+	if got := ids(filterTorrents(SearchParams{MinSize: 150, MaxSize: 250}, torrents, localFilter{promotion: true})); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("size filter = %v, want [2]", got)
+	}
+	if got := ids(filterTorrents(SearchParams{Promotion: PromotionSeventy}, torrents, localFilter{promotion: true})); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("promotion filter = %v, want [2]", got)
+	}
+	if got := ids(filterTorrents(SearchParams{Promotion: PromotionFree}, torrents, localFilter{})); len(got) != 3 {
+		t.Errorf("promotion filtered by site = %v, want all", got)
+	}
+	torrents[0].IMDbID, torrents[1].IMDbID = "tt0133093", "tt0234215"
+	torrents[0].DoubanID = "1291843"
+	params := SearchParams{IMDbID: "https://www.imdb.com/title/tt0133093/", DoubanID: "1291843"}
+	if got := ids(filterTorrents(params, torrents, localFilter{imdb: true, douban: true})); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("id filter = %v, want [1]", got)
+	}
+	if got := ids(filterTorrents(params, torrents, localFilter{})); len(got) != 3 {
+		t.Errorf("ids filtered by site = %v, want all", got)
+	}
+}
+
+func TestIDSearchError(t *testing.T) {
+	site := &Site{Name: "test"}
+	cases := []struct {
+		name   string
+		params SearchParams
+		local  localFilter
+		want   bool
+	}{
+		{"latest", SearchParams{}, localFilter{imdb: true, douban: true}, false},
+		{"keyword", SearchParams{Keyword: "Matrix", DoubanID: "1291843"}, localFilter{imdb: true, douban: true}, false},
+		{"imdb by site", SearchParams{IMDbID: "tt0133093", DoubanID: "1291843"}, localFilter{douban: true}, false},
+		{"douban", SearchParams{DoubanID: "1291843"}, localFilter{douban: true}, true},
+		{"imdb", SearchParams{IMDbID: "tt0133093"}, localFilter{imdb: true, douban: true}, true},
+	}
+	for _, c := range cases {
+		err := idSearchError(site, c.params, c.local)
+		if errors.Is(err, ErrUnsupportedSearch) != c.want {
+			t.Errorf("%s: idSearchError() = %v, want unsupported %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestSearchResult(t *testing.T) {
+	torrents := searchResult(context.Background(), SearchParams{}, []SearchTorrent{
+		{ID: "1", Title: "Movie.Name.2023.1080p.BluRay.x264-GRP"},
+		{ID: "2"},
+	}, localFilter{})
+	if p := torrents[0].Parsed; p == nil || p.Title != "Movie Name" || p.Year != 2023 || p.Group != "GRP" {
+		t.Errorf("Parsed = %+v", p)
+	}
+	if torrents[1].Parsed != nil {
+		t.Errorf("Parsed of empty title = %+v, want nil", torrents[1].Parsed)
+	}
+}
+
+func TestNormalizeIDs(t *testing.T) {
+	for in, want := range map[string]string{
+		"tt0133093":                             "tt0133093",
+		"https://www.imdb.com/title/tt0133093/": "tt0133093",
+		"133093":                                "tt0133093",
+		"":                                      "",
+	} {
+		if got := normalizeIMDbID(in); got != want {
+			t.Errorf("normalizeIMDbID(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{
+		"1291843": "1291843",
+		"https://movie.douban.com/subject/1291843/": "1291843",
+		"豆瓣": "",
+	} {
+		if got := normalizeDoubanID(in); got != want {
+			t.Errorf("normalizeDoubanID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -43,19 +43,14 @@ func (sh *helper) SaveFavicon(data []byte, site *Site, storePath string) error {
 
 func (sh *helper) GetConfigByCode(code string) (Config, error) {
 	// 根据 code 获取站点配置
-	for _, siteConfig := range globalConfig.Configs {
-		if siteConfig.ID == code {
-			return siteConfig, nil
-		}
-	}
-	return Config{}, fmt.Errorf("站点配置不存在: %s", code)
+	return defaultRegistry.GetConfigByCode(code)
 }
 
 func (sh *helper) GetDomain(site Site) (string, error) {
 	if len(site.Domain) > 0 {
 		return site.Domain, nil
 	}
-	sc, err := sh.GetConfigByCode(site.Code)
+	sc, err := siteConfig(&site)
 	if err != nil {
 		return "", err
 	}
@@ -66,7 +61,7 @@ func (sh *helper) GetApi(site Site) (string, error) {
 	if len(site.Api) > 0 {
 		return site.Api, nil
 	}
-	sc, err := sh.GetConfigByCode(site.Code)
+	sc, err := siteConfig(&site)
 	if err != nil {
 		return "", err
 	}
@@ -74,5 +69,5 @@ func (sh *helper) GetApi(site Site) (string, error) {
 }
 
 func (sh *helper) AllSupportedSites() []Config {
-	return globalConfig.Configs
+	return defaultRegistry.AllSupportedSites()
 }
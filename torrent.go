@@ -0,0 +1,211 @@
+package btsite
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 下载种子文件，不对应请求定义，用于限流、重试配置和异常信息
+const requestIdDownload requestId = "download"
+
+// maxTorrentSize 种子文件的最大体积，避免下载地址错误时读取过大的响应
+const maxTorrentSize = 32 << 20
+
+type (
+	// TorrentFile 种子文件
+	TorrentFile struct {
+		Data        []byte             // 种子文件内容
+		InfoHash    string             // v1 info-hash，SHA-1 十六进制小写，纯 v2 种子为空
+		InfoHashV2  string             // v2 info-hash，SHA-256 十六进制小写，v1 种子为空
+		Name        string             // 名称，单文件种子为文件名，多文件种子为目录名
+		Size        int64              // 总大小，单位字节，不包含填充文件
+		Files       []TorrentFileEntry // 文件列表，单文件种子只有一个文件
+		PieceLength int64              // 分块大小，单位字节
+		Trackers    []string           // tracker 地址，announce 在前，去重
+		Private     bool               // 是否为私有种子
+	}
+	// TorrentFileEntry 种子中的文件
+	TorrentFileEntry struct {
+		Path string // 相对路径，以 / 分隔，多文件种子不包含 Name 目录，单文件种子为 Name
+		Size int64  // 大小，单位字节
+	}
+)
+
+// DownloadTorrent 通过 c.GetDownloadUrl 获取下载地址，使用站点的网络配置、UA 和 Cookie 下载并解析种子文件，下载地址为相对路径时基于站点域名。
+// c 需要有 Site 方法，自定义客户端嵌入 *NexusPHPClient 即可，种子文件超过 32 MiB 时返回异常
+func DownloadTorrent(ctx context.Context, c ClientContext, torrent SearchTorrent) (*TorrentFile, error) {
+	sc, ok := c.(interface{ Site() *Site })
+	if !ok {
+		return nil, errors.New("客户端没有 Site 方法，无法下载种子文件")
+	}
+	site := sc.Site()
+	downloadUrl, err := c.GetDownloadUrl(ctx, torrent)
+	if err != nil {
+		return nil, err
+	}
+	if downloadUrl == "" {
+		return nil, fmt.Errorf("站点(%s)种子(%s)没有下载地址", site.Name, torrent.ID)
+	}
+	if u, err := url.Parse(downloadUrl); err == nil && !u.IsAbs() {
+		domain, err := SiteHelper.GetDomain(*site)
+		if err != nil {
+			return nil, err
+		}
+		if downloadUrl, err = JoinURL(domain, downloadUrl); err != nil {
+			return nil, err
+		}
+	}
+	data, err := fetch(requestSiteParams{
+		ctx:     ctx,
+		site:    site,
+		reqId:   requestIdDownload,
+		path:    downloadUrl,
+		maxSize: maxTorrentSize,
+	})
+	if err != nil {
+		return nil, newError(site, err, "下载种子文件异常")
+	}
+	tf, err := ParseTorrent(data)
+	if err != nil {
+		return nil, newError(site, err, "下载的文件不是有效的种子")
+	}
+	return tf, nil
+}
+
+// ParseTorrent 解析种子文件，支持 v1、v2 和混合种子，数据不是有效的 bencode 或缺少 info 时返回 ErrParse
+func ParseTorrent(data []byte) (*TorrentFile, error) {
+	v, d, err := decodeBencode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bencode 格式错误: %v", ErrParse, err)
+	}
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: 种子文件不是字典", ErrParse)
+	}
+	info, ok := meta["info"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: 种子文件缺少 info", ErrParse)
+	}
+	tf := &TorrentFile{
+		Data:        data,
+		Name:        bencodeString(info, "name"),
+		PieceLength: bencodeInt(info, "piece length"),
+		Private:     bencodeInt(info, "private") == 1,
+		Trackers:    trackers(meta),
+	}
+	_, v1 := info["pieces"]
+	v2 := bencodeInt(info, "meta version") == 2
+	if !v1 && !v2 {
+		return nil, fmt.Errorf("%w: info 缺少 pieces", ErrParse)
+	}
+	if v1 {
+		sum := sha1.Sum(d.info)
+		tf.InfoHash = hex.EncodeToString(sum[:])
+		tf.Files = v1Files(info)
+	}
+	if v2 {
+		sum := sha256.Sum256(d.info)
+		tf.InfoHashV2 = hex.EncodeToString(sum[:])
+		if tf.Files == nil {
+			tree, _ := info["file tree"].(map[string]any)
+			tf.Files = v2Files(tree, "")
+		}
+	}
+	for _, f := range tf.Files {
+		tf.Size += f.Size
+	}
+	return tf, nil
+}
+
+// v1Files v1 的文件列表，单文件种子使用 length，多文件种子使用 files，跳过 BEP 47 的填充文件
+func v1Files(info map[string]any) []TorrentFileEntry {
+	files, ok := info["files"].([]any)
+	if !ok {
+		return []TorrentFileEntry{{Path: bencodeString(info, "name"), Size: bencodeInt(info, "length")}}
+	}
+	entries := make([]TorrentFileEntry, 0, len(files))
+	for _, f := range files {
+		file, ok := f.(map[string]any)
+		if !ok || strings.Contains(bencodeString(file, "attr"), "p") {
+			continue
+		}
+		elems, ok := file["path.utf-8"].([]any)
+		if !ok {
+			elems, _ = file["path"].([]any)
+		}
+		var parts []string
+		for _, elem := range elems {
+			if s, ok := elem.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+		entries = append(entries, TorrentFileEntry{Path: path.Join(parts...), Size: bencodeInt(file, "length")})
+	}
+	return entries
+}
+
+// v2Files v2 的 file tree，文件为 key 为空字符串的字典，目录按名称排序递归
+func v2Files(tree map[string]any, dir string) []TorrentFileEntry {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var entries []TorrentFileEntry
+	for _, name := range names {
+		node, ok := tree[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		if file, ok := node[""].(map[string]any); ok {
+			if !strings.Contains(bencodeString(file, "attr"), "p") {
+				entries = append(entries, TorrentFileEntry{Path: path.Join(dir, name), Size: bencodeInt(file, "length")})
+			}
+			continue
+		}
+		entries = append(entries, v2Files(node, path.Join(dir, name))...)
+	}
+	return entries
+}
+
+// trackers announce 和 announce-list 的所有 tracker，保持顺序并去重
+func trackers(meta map[string]any) []string {
+	var result []string
+	seen := make(map[string]bool)
+	add := func(v any) {
+		if s, ok := v.(string); ok && s != "" && !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	add(meta["announce"])
+	tiers, _ := meta["announce-list"].([]any)
+	for _, tier := range tiers {
+		list, _ := tier.([]any)
+		for _, tracker := range list {
+			add(tracker)
+		}
+	}
+	return result
+}
+
+func bencodeString(dict map[string]any, key string) string {
+	if s, ok := dict[key+".utf-8"].(string); ok {
+		return s
+	}
+	s, _ := dict[key].(string)
+	return s
+}
+
+func bencodeInt(dict map[string]any, key string) int64 {
+	n, _ := dict[key].(int64)
+	return n
+}
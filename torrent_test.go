@@ -0,0 +1,189 @@
+package btsite_test
+
+import (
+	"context"
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-siteadapt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readTorrent(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "torrent", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseTorrent(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want btsite.TorrentFile
+	}{
+		{"v1", readTorrent(t, "v1.torrent"), btsite.TorrentFile{
+			InfoHash: "e2bf155b08156d14fa8014b8556bcdc7c7b578a0",
+			Name:     "The.Matrix.1999",
+			Size:     402048,
+			Files: []btsite.TorrentFileEntry{
+				{Path: "The.Matrix.1999.mkv", Size: 400000},
+				{Path: "Subs/chs.srt", Size: 2048},
+			},
+			PieceLength: 262144,
+			Trackers:    []string{"https://tracker.example/announce.php?passkey=abc", "https://backup.example/announce"},
+			Private:     true,
+		}},
+		{"hybrid", readTorrent(t, "hybrid.torrent"), btsite.TorrentFile{
+			InfoHash:    "79b95b33d0babe4e7450e99ae7d088f67c1d8a85",
+			InfoHashV2:  "0dba0a0ddd29e805a97ea318aac5a04a39a9f8f1de1fdefe054079a582dd6080",
+			Name:        "movie.mkv",
+			Size:        16000,
+			Files:       []btsite.TorrentFileEntry{{Path: "movie.mkv", Size: 16000}},
+			PieceLength: 16384,
+			Trackers:    []string{"https://tracker.example/announce"},
+		}},
+		{"v2", readTorrent(t, "v2.torrent"), btsite.TorrentFile{
+			InfoHashV2:  "9a64f701462dd1b2abe2b0cb0635c2e3d3189a627c9e81429692befb91524ad7",
+			Name:        "Season.01",
+			Size:        1500,
+			Files:       []btsite.TorrentFileEntry{{Path: "E01.mkv", Size: 1000}, {Path: "Extras/trailer.mkv", Size: 500}},
+			PieceLength: 16384,
+			Trackers:    []string{"https://tracker.example/announce"},
+			Private:     true,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := btsite.ParseTorrent(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Data = tt.data
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseTorrent() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTorrentInvalid(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"<html></html>",
+		"d4:infod6:lengthi1e4:name1:x6:pieces0:ee trailing",
+		"d4:infod6:lengthi01e4:name1:x6:pieces0:eee",
+		"d8:announce3:urle",
+		"d4:infod4:name1:xee",
+		"li1ee",
+	} {
+		if _, err := btsite.ParseTorrent([]byte(data)); !errors.Is(err, btsite.ErrParse) {
+			t.Errorf("ParseTorrent(%q) err = %v, want ErrParse", data, err)
+		}
+	}
+}
+
+func TestDownloadTorrent(t *testing.T) {
+	c, _ := newFixtureClient(t, "Gazelle", "torrent", func(r *http.Request) string {
+		if r.Header.Get("Cookie") != "session=fixture" {
+			return ""
+		}
+		switch r.URL.Query().Get("id") {
+		case "1":
+			return "v1.torrent"
+		case "2":
+			return "login.html"
+		}
+		return ""
+	})
+	tf, err := btsite.DownloadTorrent(context.Background(), c, btsite.SearchTorrent{ID: "1", Enclosure: "torrents.php?action=download&id=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tf.InfoHash != "e2bf155b08156d14fa8014b8556bcdc7c7b578a0" || len(tf.Data) == 0 {
+		t.Errorf("DownloadTorrent() = %+v", tf)
+	}
+	_, err = btsite.DownloadTorrent(context.Background(), c, btsite.SearchTorrent{ID: "2", Enclosure: "torrents.php?action=download&id=2"})
+	if !errors.Is(err, btsite.ErrParse) {
+		t.Errorf("DownloadTorrent(html) err = %v, want ErrParse", err)
+	}
+	_, err = btsite.DownloadTorrent(context.Background(), c, btsite.SearchTorrent{ID: "3", Enclosure: "torrents.php?action=download&id=3"})
+	var se *btsite.SiteError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound || se.RequestId != "download" {
+		t.Errorf("DownloadTorrent(404) err = %v, want SiteError 404", err)
+	}
+}
+
+func TestGetDownloadUrlById(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+	np := newTestSite(t, srv, nil)
+	if got, err := np.GetDownloadUrl(ctx, btsite.SearchTorrent{ID: "42"}); err != nil || got != srv.URL+"/download.php?id=42" {
+		t.Errorf("NexusPHP GetDownloadUrl() = %q, %v", got, err)
+	}
+	if got, _ := np.GetDownloadUrl(ctx, btsite.SearchTorrent{ID: "42", Enclosure: "https://example.com/dl"}); got != "https://example.com/dl" {
+		t.Errorf("NexusPHP GetDownloadUrl() = %q, want enclosure", got)
+	}
+	// Discuz 的种子为帖子附件，无法根据 id 拼接
+	dz := newDiscuzClient(t, "", "")
+	if got, err := dz.GetDownloadUrl(ctx, btsite.SearchTorrent{ID: "42"}); err != nil || got != "" {
+		t.Errorf("Discuz GetDownloadUrl() = %q, %v, want empty", got, err)
+	}
+}
+
+// tokenClient 嵌入 NexusPHPClient，只重写 GetDownloadUrl
+type tokenClient struct {
+	*btsite.NexusPHPClient
+}
+
+func (c *tokenClient) GetDownloadUrl(ctx context.Context, torrent btsite.SearchTorrent) (string, error) {
+	return "download.php?id=" + torrent.ID + "&token=t", nil
+}
+
+func TestDownloadTorrentOuterClient(t *testing.T) {
+	err := btsite.RegisterSchema("go-btsite-token", func(site *btsite.Site) btsite.ClientContext {
+		return &tokenClient{btsite.NewNexusPHPClient(site)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := readTorrent(t, "v1.torrent")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("id") {
+		case "1":
+			if r.URL.Query().Get("token") != "t" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		case "2":
+			// 超过种子文件的最大体积
+			_, _ = w.Write(make([]byte, 32<<20+1))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	r := btsite.NewRegistry(&btsite.AdaptCfg{Configs: []btsite.Config{{
+		Config: siteadapt.Config{ID: "token", Domain: srv.URL},
+		Schema: "go-btsite-token",
+	}}})
+	c, err := r.NewClientContext(&btsite.Site{Code: "token", Name: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf, err := btsite.DownloadTorrent(context.Background(), c, btsite.SearchTorrent{ID: "1"})
+	if err != nil || tf.InfoHash != "e2bf155b08156d14fa8014b8556bcdc7c7b578a0" {
+		t.Errorf("DownloadTorrent() = %+v, %v", tf, err)
+	}
+	_, err = btsite.DownloadTorrent(context.Background(), c, btsite.SearchTorrent{ID: "2"})
+	var se *btsite.SiteError
+	if !errors.As(err, &se) || se.RequestId != "download" {
+		t.Errorf("DownloadTorrent(oversized) err = %v, want SiteError", err)
+	}
+}
@@ -0,0 +1,485 @@
+// Package torznab 将站点适配为 Torznab 接口，可直接添加到 Sonarr、Radarr 等工具，无需 Jackett。
+//
+// 接口地址为 /{site}/api，site 为站点唯一标识，支持 t=caps、search、tvsearch、movie，
+// 种子没有下载链接时，下载地址指向 /{site}/download，由客户端的 GetDownloadUrl 获取后跳转。
+package torznab
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/heibizi/go-btsite"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Torznab 标准分类
+const (
+	CategoryMovies = 2000
+	CategoryTV     = 5000
+	CategoryOther  = 8000
+)
+
+// Torznab 错误码
+const (
+	errorCodeIncorrectCredentials = 100
+	errorCodeMissingParameter     = 200
+	errorCodeIncorrectParameter   = 201
+	errorCodeNoSuchFunction       = 202
+	errorCodeUnknown              = 900
+)
+
+const (
+	defaultLimit = 100
+	// maxOffset offset 的上限，offset 跨页时需要从第一页开始逐页请求，限制单次请求最多请求站点的种子数量
+	maxOffset = 5 * defaultLimit
+)
+
+type (
+	// Options Handler 配置
+	Options struct {
+		APIKey   string           // 接口密钥，为空时不校验
+		Sites    []*btsite.Site   // 对外提供的站点
+		Registry *btsite.Registry // 站点配置注册表，为空时使用默认注册表
+		Timeout  time.Duration    // 单次搜索的超时时间，<= 0 时不限制
+		BaseURL  string           // 对外访问的地址，用于生成下载地址，为空时根据请求推断
+		Title    string           // caps 中的服务名称，默认为 btsite
+		Logger   func(err error)  // 站点异常回调，可用于记录日志，响应中只返回通用的错误信息
+
+		// 是否信任 X-Forwarded-Proto 请求头，仅在部署于会覆盖该请求头的反向代理之后时开启，配置了 BaseURL 时无效
+		TrustForwardedProto bool
+	}
+	// Handler Torznab 接口
+	Handler struct {
+		opts  Options
+		sites map[string]*btsite.Site
+		mux   *http.ServeMux
+	}
+	rss struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		Atom    string   `xml:"xmlns:atom,attr"`
+		Torznab string   `xml:"xmlns:torznab,attr"`
+		Channel channel  `xml:"channel"`
+	}
+	channel struct {
+		Title string `xml:"title"`
+		Items []item `xml:"item"`
+	}
+	item struct {
+		Title     string    `xml:"title"`
+		GUID      string    `xml:"guid"`
+		Link      string    `xml:"link"`
+		Comments  string    `xml:"comments,omitempty"`
+		PubDate   string    `xml:"pubDate,omitempty"`
+		Size      int64     `xml:"size"`
+		Category  int       `xml:"category"`
+		Enclosure enclosure `xml:"enclosure"`
+		Attrs     []attr    `xml:"torznab:attr"`
+	}
+	enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+		Type   string `xml:"type,attr"`
+	}
+	attr struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+	caps struct {
+		XMLName    xml.Name       `xml:"caps"`
+		Server     capsServer     `xml:"server"`
+		Limits     capsLimits     `xml:"limits"`
+		Searching  capsSearching  `xml:"searching"`
+		Categories []capsCategory `xml:"categories>category"`
+	}
+	capsServer struct {
+		Title string `xml:"title,attr"`
+	}
+	capsLimits struct {
+		Max     int `xml:"max,attr"`
+		Default int `xml:"default,attr"`
+	}
+	capsSearching struct {
+		Search      capsSearch `xml:"search"`
+		TVSearch    capsSearch `xml:"tv-search"`
+		MovieSearch capsSearch `xml:"movie-search"`
+	}
+	capsSearch struct {
+		Available       string `xml:"available,attr"`
+		SupportedParams string `xml:"supportedParams,attr"`
+	}
+	capsCategory struct {
+		ID   int    `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	}
+	errorResponse struct {
+		XMLName     xml.Name `xml:"error"`
+		Code        int      `xml:"code,attr"`
+		Description string   `xml:"description,attr"`
+	}
+)
+
+// NewHandler 创建 Torznab 接口
+func NewHandler(opts Options) *Handler {
+	if opts.Registry == nil {
+		opts.Registry = btsite.DefaultRegistry()
+	}
+	if opts.Title == "" {
+		opts.Title = "btsite"
+	}
+	h := &Handler{
+		opts:  opts,
+		sites: make(map[string]*btsite.Site, len(opts.Sites)),
+		mux:   http.NewServeMux(),
+	}
+	for _, site := range opts.Sites {
+		h.sites[site.Code] = site
+	}
+	h.mux.HandleFunc("GET /{site}/api", h.api)
+	h.mux.HandleFunc("GET /{site}/download", h.download)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) api(w http.ResponseWriter, r *http.Request) {
+	site, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	switch t := q.Get("t"); t {
+	case "caps":
+		writeXML(w, http.StatusOK, h.caps())
+	case "search", "tvsearch", "movie":
+		h.search(w, r, site, t)
+	case "":
+		writeError(w, http.StatusBadRequest, errorCodeMissingParameter, "Missing parameter (t)")
+	default:
+		writeError(w, http.StatusBadRequest, errorCodeNoSuchFunction, "No such function ("+t+")")
+	}
+}
+
+// download 获取种子的下载地址并跳转
+func (h *Handler) download(w http.ResponseWriter, r *http.Request) {
+	site, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, errorCodeMissingParameter, "Missing parameter (id)")
+		return
+	}
+	c, err := h.opts.Registry.NewClientContext(site)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	ctx, cancel := h.context(r)
+	defer cancel()
+	downloadUrl, err := c.GetDownloadUrl(ctx, btsite.SearchTorrent{ID: id})
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	if downloadUrl == "" {
+		writeError(w, http.StatusNotFound, errorCodeIncorrectParameter, "Torrent not found")
+		return
+	}
+	http.Redirect(w, r, downloadUrl, http.StatusFound)
+}
+
+// authorize 校验接口密钥和站点，失败时写入错误响应
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) (*btsite.Site, bool) {
+	if h.opts.APIKey != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("apikey")), []byte(h.opts.APIKey)) != 1 {
+		writeError(w, http.StatusUnauthorized, errorCodeIncorrectCredentials, "Incorrect user credentials")
+		return nil, false
+	}
+	site, ok := h.sites[r.PathValue("site")]
+	if !ok {
+		writeError(w, http.StatusNotFound, errorCodeIncorrectParameter, "Unknown site")
+		return nil, false
+	}
+	return site, true
+}
+
+func (h *Handler) search(w http.ResponseWriter, r *http.Request, site *btsite.Site, t string) {
+	q := r.URL.Query()
+	offset, err := intParam(q, "offset", 0)
+	if err == nil && offset > maxOffset {
+		err = fmt.Errorf("Incorrect parameter (offset), max %d", maxOffset)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorCodeIncorrectParameter, err.Error())
+		return
+	}
+	limit, err := intParam(q, "limit", defaultLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorCodeIncorrectParameter, err.Error())
+		return
+	}
+	searchParams, category := searchParams(q, t)
+	c, err := h.opts.Registry.NewClientContext(site)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	ctx, cancel := h.context(r)
+	defer cancel()
+	if limit > defaultLimit {
+		limit = defaultLimit
+	}
+	// offset 跨页时自动翻页，跳过前 offset 个种子
+	var torrents []btsite.SearchTorrent
+	if limit > 0 {
+		cursor := btsite.SearchAll(ctx, c, searchParams, offset+limit)
+		for cursor.Next() {
+			if cursor.Count() > offset {
+				torrents = append(torrents, cursor.Torrent())
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			if len(torrents) == 0 {
+				h.fail(w, err)
+				return
+			}
+			// 已获取到部分种子时返回已有的结果
+			h.log(err)
+		}
+	}
+	sc, _ := h.opts.Registry.GetConfigByCode(site.Code)
+	base := h.baseURL(r)
+	feed := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Torznab: "http://torznab.com/schemas/2015/feed",
+		Channel: channel{Title: site.Name},
+	}
+	for _, torrent := range torrents {
+		cat := category
+		if cat == 0 {
+			cat = torrentCategory(sc, torrent)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, h.item(base, site, torrent, cat))
+	}
+	writeXML(w, http.StatusOK, feed)
+}
+
+func (h *Handler) item(base string, site *btsite.Site, torrent btsite.SearchTorrent, category int) item {
+	link := torrent.Enclosure
+	if link == "" {
+		v := url.Values{"id": {torrent.ID}}
+		if h.opts.APIKey != "" {
+			v.Set("apikey", h.opts.APIKey)
+		}
+		link = base + "/" + url.PathEscape(site.Code) + "/download?" + v.Encode()
+	}
+	guid := torrent.PageURL
+	if guid == "" {
+		guid = site.Code + "-" + torrent.ID
+	}
+	it := item{
+		Title:    torrent.Title,
+		GUID:     guid,
+		Link:     link,
+		Comments: torrent.PageURL,
+		Size:     torrent.Size,
+		Category: category,
+		Enclosure: enclosure{
+			URL:    link,
+			Length: torrent.Size,
+			Type:   "application/x-bittorrent",
+		},
+		Attrs: []attr{
+			{"category", strconv.Itoa(category)},
+			{"size", strconv.FormatInt(torrent.Size, 10)},
+			{"seeders", strconv.Itoa(torrent.Seeders)},
+			{"peers", strconv.Itoa(torrent.Seeders + torrent.Leechers)},
+			{"grabs", strconv.Itoa(torrent.Grabs)},
+			{"downloadvolumefactor", formatFloat(torrent.DownloadVolumeFactor)},
+			{"uploadvolumefactor", formatFloat(torrent.UploadVolumeFactor)},
+		},
+	}
+	if torrent.PubDate > 0 {
+		it.PubDate = time.Unix(torrent.PubDate, 0).Format(time.RFC1123Z)
+	}
+	if torrent.Description != "" {
+		it.Attrs = append(it.Attrs, attr{"description", torrent.Description})
+	}
+	if torrent.InfoHash != "" {
+		it.Attrs = append(it.Attrs, attr{"infohash", torrent.InfoHash})
+	}
+	if torrent.IMDbID != "" {
+		it.Attrs = append(it.Attrs, attr{"imdbid", torrent.IMDbID})
+	}
+	if torrent.DoubanID != "" {
+		it.Attrs = append(it.Attrs, attr{"doubanid", torrent.DoubanID})
+	}
+	if torrent.PosterURL != "" {
+		it.Attrs = append(it.Attrs, attr{"coverurl", torrent.PosterURL})
+	}
+	return it
+}
+
+func (h *Handler) caps() caps {
+	return caps{
+		Server: capsServer{Title: h.opts.Title},
+		Limits: capsLimits{Max: defaultLimit, Default: defaultLimit},
+		Searching: capsSearching{
+			Search:      capsSearch{Available: "yes", SupportedParams: "q"},
+			TVSearch:    capsSearch{Available: "yes", SupportedParams: "q,season,ep"},
+			MovieSearch: capsSearch{Available: "yes", SupportedParams: "q,imdbid"},
+		},
+		Categories: []capsCategory{
+			{CategoryMovies, "Movies"},
+			{CategoryTV, "TV"},
+			{CategoryOther, "Other"},
+		},
+	}
+}
+
+// context 请求的 context，配置了超时时间时附加超时
+func (h *Handler) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if h.opts.Timeout > 0 {
+		return context.WithTimeout(r.Context(), h.opts.Timeout)
+	}
+	return context.WithCancel(r.Context())
+}
+
+// baseURL 对外访问的地址，不以 / 结尾
+func (h *Handler) baseURL(r *http.Request) string {
+	if h.opts.BaseURL != "" {
+		return strings.TrimSuffix(h.opts.BaseURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); h.opts.TrustForwardedProto && (forwarded == "http" || forwarded == "https") {
+		scheme = forwarded
+	}
+	return scheme + "://" + r.Host
+}
+
+// fail 写入站点异常，未登录返回 401，其他返回 502，异常详情可能包含站点地址等信息，只写入日志
+func (h *Handler) fail(w http.ResponseWriter, err error) {
+	h.log(err)
+	if errors.Is(err, btsite.ErrNotLoggedIn) {
+		writeError(w, http.StatusUnauthorized, errorCodeUnknown, "Site login required")
+		return
+	}
+	if errors.Is(err, btsite.ErrUnsupportedSearch) {
+		writeError(w, http.StatusBadRequest, errorCodeIncorrectParameter, "Unsupported search parameter")
+		return
+	}
+	writeError(w, http.StatusBadGateway, errorCodeUnknown, "Site request failed")
+}
+
+func (h *Handler) log(err error) {
+	if h.opts.Logger != nil {
+		h.opts.Logger(err)
+	}
+}
+
+// searchParams 将 Torznab 请求参数转换为搜索参数，返回的分类为 0 时根据种子分类判断
+func searchParams(q url.Values, t string) (btsite.SearchParams, int) {
+	sp := btsite.SearchParams{Keyword: strings.TrimSpace(q.Get("q"))}
+	category := 0
+	switch t {
+	case "movie":
+		sp.MediaType = btsite.Movie
+		category = CategoryMovies
+		if imdbID := strings.TrimSpace(q.Get("imdbid")); imdbID != "" {
+			sp.IMDbID = "tt" + strings.TrimPrefix(imdbID, "tt")
+		}
+	case "tvsearch":
+		sp.MediaType = btsite.Tv
+		category = CategoryTV
+		if episode := episode(q.Get("season"), q.Get("ep")); episode != "" {
+			sp.Keyword = strings.TrimSpace(sp.Keyword + " " + episode)
+		}
+	default:
+		// 通用搜索根据 cat 参数判断媒体类型
+		for _, cat := range strings.Split(q.Get("cat"), ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(cat))
+			if err != nil {
+				continue
+			}
+			switch id / 1000 * 1000 {
+			case CategoryMovies:
+				sp.MediaType = btsite.Movie
+			case CategoryTV:
+				sp.MediaType = btsite.Tv
+			}
+		}
+	}
+	return sp, category
+}
+
+// episode 季和集转换为 S01E02 格式
+func episode(season string, ep string) string {
+	s, err := strconv.Atoi(season)
+	if err != nil {
+		return ""
+	}
+	if e, err := strconv.Atoi(ep); err == nil {
+		return fmt.Sprintf("S%02dE%02d", s, e)
+	}
+	return fmt.Sprintf("S%02d", s)
+}
+
+// torrentCategory 根据站点配置的分类判断种子的 Torznab 分类
+func torrentCategory(sc btsite.Config, torrent btsite.SearchTorrent) int {
+	for _, cat := range sc.Categories.Movie {
+		if cat.ID == torrent.Category || (cat.Desc != "" && cat.Desc == torrent.Category) {
+			return CategoryMovies
+		}
+	}
+	for _, cat := range sc.Categories.TV {
+		if cat.ID == torrent.Category || (cat.Desc != "" && cat.Desc == torrent.Category) {
+			return CategoryTV
+		}
+	}
+	return CategoryOther
+}
+
+func intParam(q url.Values, name string, def int) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("Incorrect parameter (%s)", name)
+	}
+	return i, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, status int, code int, description string) {
+	writeXML(w, status, errorResponse{Code: code, Description: description})
+}
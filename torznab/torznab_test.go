@@ -0,0 +1,290 @@
+package torznab_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"github.com/heibizi/go-btsite/torznab"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// fakeClient 第一页返回固定的两个种子，之后的页返回 pages 页内编号递增的种子
+type fakeClient struct {
+	btsite.ClientContext
+	searchParams *btsite.SearchParams
+	pages        int
+	err          error
+}
+
+func (c *fakeClient) Search(ctx context.Context, searchParams btsite.SearchParams) ([]btsite.SearchTorrent, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if searchParams.Page > 0 {
+		if searchParams.Page >= c.pages {
+			return nil, nil
+		}
+		first := searchParams.Page*2 + 1
+		return []btsite.SearchTorrent{
+			{ID: strconv.Itoa(first), Title: "Page" + strconv.Itoa(first)},
+			{ID: strconv.Itoa(first + 1), Title: "Page" + strconv.Itoa(first+1)},
+		}, nil
+	}
+	*c.searchParams = searchParams
+	return []btsite.SearchTorrent{
+		{
+			ID:                   "1",
+			Category:             "401",
+			Title:                "Movie.2024.1080p",
+			PageURL:              "https://fake.example/details.php?id=1",
+			Enclosure:            "https://fake.example/download.php?id=1",
+			Seeders:              10,
+			Leechers:             2,
+			Grabs:                30,
+			Size:                 1024,
+			DownloadVolumeFactor: 0,
+			UploadVolumeFactor:   2,
+			PubDate:              1714550400,
+		},
+		{
+			ID:                   "2",
+			Category:             "999",
+			Title:                "Other",
+			DownloadVolumeFactor: 1,
+			UploadVolumeFactor:   1,
+		},
+	}, nil
+}
+
+func (c *fakeClient) GetDownloadUrl(ctx context.Context, torrent btsite.SearchTorrent) (string, error) {
+	return "https://fake.example/dl/" + torrent.ID, nil
+}
+
+func newHandler(t *testing.T, opts ...func(c *fakeClient, o *torznab.Options)) (*torznab.Handler, *btsite.SearchParams) {
+	t.Helper()
+	var searchParams btsite.SearchParams
+	fake := fakeClient{searchParams: &searchParams, pages: 1}
+	schema := "Torznab" + t.Name()
+	err := btsite.RegisterSchema(schema, func(site *btsite.Site) btsite.ClientContext {
+		c := fake
+		return &c
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := btsite.LoadConfig(fstest.MapFS{
+		"commons": {Mode: fs.ModeDir},
+		"sites/fake.json": {Data: []byte(`{"id": "fake", "name": "Fake", "schema": "` + schema + `",
+			"category": {"movie": [{"id": "401", "desc": "Movies"}], "tv": [{"id": "402", "desc": "TV"}]}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := torznab.Options{
+		APIKey:   "secret",
+		Sites:    []*btsite.Site{{Code: "fake", Name: "Fake"}},
+		Registry: btsite.NewRegistry(conf),
+		BaseURL:  "http://torznab.example/",
+	}
+	for _, opt := range opts {
+		opt(&fake, &o)
+	}
+	return torznab.NewHandler(o), &searchParams
+}
+
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestAPIKey(t *testing.T) {
+	h, _ := newHandler(t)
+	for _, target := range []string{"/fake/api?t=caps", "/fake/api?t=caps&apikey=wrong", "/fake/download?id=1"} {
+		w := get(h, target)
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `code="100"`) {
+			t.Errorf("%s: got %d %s", target, w.Code, w.Body)
+		}
+	}
+	if w := get(h, "/unknown/api?t=caps&apikey=secret"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown site: got %d", w.Code)
+	}
+}
+
+func TestCaps(t *testing.T) {
+	h, _ := newHandler(t)
+	w := get(h, "/fake/api?t=caps&apikey=secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	for _, want := range []string{"<caps>", `<tv-search available="yes" supportedParams="q,season,ep">`, `<category id="2000" name="Movies">`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("caps missing %s: %s", want, w.Body)
+		}
+	}
+	if w := get(h, "/fake/api?t=music&apikey=secret"); !strings.Contains(w.Body.String(), `code="202"`) {
+		t.Errorf("got %s", w.Body)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	h, searchParams := newHandler(t)
+	w := get(h, "/fake/api?t=search&q=Movie&cat=2000,2040&apikey=secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if searchParams.Keyword != "Movie" || searchParams.MediaType != btsite.Movie {
+		t.Errorf("search params = %+v", *searchParams)
+	}
+	var feed struct {
+		Items []struct {
+			Title     string `xml:"title"`
+			Link      string `xml:"link"`
+			PubDate   string `xml:"pubDate"`
+			Category  int    `xml:"category"`
+			Enclosure struct {
+				URL string `xml:"url,attr"`
+			} `xml:"enclosure"`
+			Attrs []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:"value,attr"`
+			} `xml:"attr"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Items))
+	}
+	first := feed.Items[0]
+	if first.Category != torznab.CategoryMovies || first.Enclosure.URL != "https://fake.example/download.php?id=1" || first.PubDate == "" {
+		t.Errorf("got %+v", first)
+	}
+	attrs := map[string]string{}
+	for _, a := range first.Attrs {
+		attrs[a.Name] = a.Value
+	}
+	for name, want := range map[string]string{"size": "1024", "seeders": "10", "peers": "12", "downloadvolumefactor": "0", "uploadvolumefactor": "2"} {
+		if attrs[name] != want {
+			t.Errorf("attr %s = %q, want %q", name, attrs[name], want)
+		}
+	}
+	second := feed.Items[1]
+	if second.Category != torznab.CategoryOther || second.Link != "http://torznab.example/fake/download?apikey=secret&id=2" {
+		t.Errorf("got %+v", second)
+	}
+}
+
+func TestTVSearch(t *testing.T) {
+	h, searchParams := newHandler(t)
+	w := get(h, "/fake/api?t=tvsearch&q=Show&season=1&ep=2&limit=1&apikey=secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if searchParams.Keyword != "Show S01E02" || searchParams.MediaType != btsite.Tv {
+		t.Errorf("search params = %+v", *searchParams)
+	}
+	if n := strings.Count(w.Body.String(), "<item>"); n != 1 {
+		t.Errorf("got %d items, want 1", n)
+	}
+}
+
+func TestMovieSearch(t *testing.T) {
+	t.Run("imdbid", func(t *testing.T) {
+		h, searchParams := newHandler(t)
+		w := get(h, "/fake/api?t=movie&q=Matrix&imdbid=0133093&apikey=secret")
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d %s", w.Code, w.Body)
+		}
+		if searchParams.Keyword != "Matrix" || searchParams.IMDbID != "tt0133093" || searchParams.MediaType != btsite.Movie {
+			t.Errorf("search params = %+v", *searchParams)
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		h, _ := newHandler(t, func(c *fakeClient, o *torznab.Options) {
+			c.err = btsite.ErrUnsupportedSearch
+		})
+		w := get(h, "/fake/api?t=movie&imdbid=tt0133093&apikey=secret")
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `code="201"`) {
+			t.Errorf("got %d %s", w.Code, w.Body)
+		}
+	})
+}
+
+func TestDownload(t *testing.T) {
+	h, _ := newHandler(t)
+	w := get(h, "/fake/download?id=2&apikey=secret")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://fake.example/dl/2" {
+		t.Errorf("got %d %s", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestSearchOffset(t *testing.T) {
+	h, _ := newHandler(t, func(c *fakeClient, o *torznab.Options) {
+		c.pages = 3
+	})
+	w := get(h, "/fake/api?t=search&q=Movie&offset=3&limit=2&apikey=secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	if strings.Count(body, "<item>") != 2 || !strings.Contains(body, "<title>Page4</title>") || !strings.Contains(body, "<title>Page5</title>") {
+		t.Errorf("got %s, want Page4 and Page5", body)
+	}
+}
+
+func TestSearchOffsetLimit(t *testing.T) {
+	// 超过上限时不请求站点
+	h, _ := newHandler(t, func(c *fakeClient, o *torznab.Options) {
+		c.err = errors.New("unexpected search")
+	})
+	w := get(h, "/fake/api?t=search&q=Movie&offset=100000&limit=2&apikey=secret")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `code="201"`) {
+		t.Errorf("got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestSiteErrorNotExposed(t *testing.T) {
+	var logged error
+	h, _ := newHandler(t, func(c *fakeClient, o *torznab.Options) {
+		c.err = errors.New("GET https://fake.example/torrents.php?passkey=leak: 500")
+		o.Logger = func(err error) { logged = err }
+	})
+	w := get(h, "/fake/api?t=search&q=Movie&apikey=secret")
+	if w.Code != http.StatusBadGateway || strings.Contains(w.Body.String(), "leak") {
+		t.Errorf("got %d %s", w.Code, w.Body)
+	}
+	if logged == nil || !strings.Contains(logged.Error(), "leak") {
+		t.Errorf("logged %v, want site error", logged)
+	}
+}
+
+func TestForwardedProto(t *testing.T) {
+	for _, trust := range []bool{false, true} {
+		t.Run(strconv.FormatBool(trust), func(t *testing.T) {
+			h, _ := newHandler(t, func(c *fakeClient, o *torznab.Options) {
+				o.BaseURL = ""
+				o.TrustForwardedProto = trust
+			})
+			r := httptest.NewRequest(http.MethodGet, "http://torznab.example/fake/api?t=search&q=Movie&apikey=secret", nil)
+			r.Header.Set("X-Forwarded-Proto", "https")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			want := "http://torznab.example/fake/download"
+			if trust {
+				want = "https://torznab.example/fake/download"
+			}
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("got %s, want link %s", w.Body, want)
+			}
+		})
+	}
+}
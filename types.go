@@ -2,6 +2,9 @@ package btsite
 
 import (
 	"encoding/xml"
+	"github.com/heibizi/go-btsite/release"
+	"net/http"
+	"time"
 )
 
 // 内部使用
@@ -24,6 +27,12 @@ type (
 		Description          string   `mapstructure:"description,omitempty"`
 		Labels               []string `mapstructure:"labels,omitempty"`
 		HrDays               int      `mapstructure:"hr_days,omitempty"`
+		InfoHash             string   `mapstructure:"infohash,omitempty"`
+		IMDbID               string   `mapstructure:"imdbid,omitempty"`
+		DoubanID             string   `mapstructure:"doubanid,omitempty"`
+		Poster               string   `mapstructure:"poster,omitempty"`
+		Subtitle             string   `mapstructure:"subtitle,omitempty"`
+		FreeDeadline         string   `mapstructure:"free_deadline,omitempty"`
 	}
 	// seeding 做种信息
 	seeding struct {
@@ -79,23 +88,32 @@ type (
 	}
 	// SearchTorrent 搜索种子
 	SearchTorrent struct {
-		ID                   string   // ID
-		Category             string   // 分类
-		Title                string   // 标题
-		Description          string   // 描述
-		PageURL              string   // 详情页
-		Enclosure            string   // 下载链接
-		Grabs                int      // 完成数
-		Seeders              int      // 做种人数
-		Leechers             int      // 下载人数
-		Size                 int64    // 体积
-		DownloadVolumeFactor float64  // 下载系数
-		UploadVolumeFactor   float64  // 上传系数
-		PubDate              string   // 发布时间
-		DateElapsed          string   // 剩余时间
-		HrDays               int      // HitAndRun 天数
-		HitAndRun            bool     // 是否 HitAndRun
-		Labels               []string // 标签
+		ID                   string        // ID
+		Category             string        // 分类
+		Title                string        // 标题
+		Description          string        // 描述
+		PageURL              string        // 详情页
+		Enclosure            string        // 下载链接
+		Grabs                int           // 完成数
+		Seeders              int           // 做种人数
+		Leechers             int           // 下载人数
+		Size                 int64         // 体积
+		DownloadVolumeFactor float64       // 下载系数
+		UploadVolumeFactor   float64       // 上传系数
+		PubDate              int64         // 发布时间，unix 时间戳，单位秒，不带时区的时间按站点配置的 timezone 解析，未知时为 0
+		PubDateRaw           string        // 站点返回的原始发布时间
+		DateElapsed          time.Duration // 存活时间，未知时为 0
+		DateElapsedRaw       string        // 站点返回的原始存活时间，如 3天12时
+		HrDays               int           // HitAndRun 天数
+		HitAndRun            bool          // 是否 HitAndRun
+		Labels               []string      // 标签
+		InfoHash             string        // v1 info-hash，40 位十六进制小写，来自 infohash 字段或磁力链接，未知时为空
+		IMDbID               string        // IMDb 编号，如 tt0133093
+		DoubanID             string        // 豆瓣编号
+		PosterURL            string        // 海报地址
+		Subtitle             string        // 副标题
+		PromotionExpiresAt   int64         // 促销截止时间，unix 时间戳，单位秒，未知或没有截止时间时为 0
+		Parsed               *release.Info // 标题的发布名解析结果，标题为空时为 nil
 	}
 	// SeedingStatistics 做种统计
 	SeedingStatistics struct {
@@ -110,8 +128,11 @@ type (
 		Downloaded              string `mapstructure:"downloaded,omitempty"`                // 下载量
 		ShareRatio              string `mapstructure:"share_ratio,omitempty"`               // 分享率
 		DownloadTime            string `mapstructure:"download_time,omitempty"`             // 下载时间，或者统计时间
-		NeedSeedTime            string `mapstructure:"need_seed_time,omitempty"`            // 需要做种时间
-		RemainingInspectionTime string `mapstructure:"remaining_inspection_time,omitempty"` // 剩余时间
+		NeedSeedTime            string `mapstructure:"need_seed_time,omitempty"`            // 需要做种时间，站点返回的原始文本，如 3天12时
+		RemainingInspectionTime string `mapstructure:"remaining_inspection_time,omitempty"` // 剩余时间，站点返回的原始文本
+
+		NeedSeedDuration            time.Duration `mapstructure:"-"` // 需要做种时间，由 NeedSeedTime 解析，无法解析时为 0
+		RemainingInspectionDuration time.Duration `mapstructure:"-"` // 剩余时间，由 RemainingInspectionTime 解析，无法解析时为 0
 	}
 	// Message 未读消息
 	Message struct {
@@ -129,13 +150,14 @@ type (
 	}
 	// RssTorrent RSS 拉取的数据
 	RssTorrent struct {
-		ID          string // ID
-		Title       string // 标题
-		Enclosure   string // 下载链接
-		Size        int64  // 体积，单位字节
-		Description string // 描述
-		Link        string // 详情页
-		PubDate     int64  // 发布时间
+		ID          string        // ID
+		Title       string        // 标题
+		Enclosure   string        // 下载链接
+		Size        int64         // 体积，单位字节
+		Description string        // 描述
+		Link        string        // 详情页
+		PubDate     int64         // 发布时间
+		Parsed      *release.Info // 标题的发布名解析结果，标题为空时为 nil
 	}
 	// SignInResult 签到结果
 	SignInResult struct {
@@ -156,20 +178,41 @@ type (
 type (
 	// SearchParams 搜索种子参数
 	SearchParams struct {
-		Keyword   string // 关键字
-		MediaType MediaType
-		Page      int
+		Keyword     string    // 关键字
+		MediaType   MediaType // 媒体类型，对应站点配置的分类
+		Page        int       // 页码，从 0 开始
+		IMDbID      string    // IMDb 编号，如 tt0133093，站点不支持时按搜索结果的 IMDb 编号过滤，没有关键字时返回 ErrUnsupportedSearch
+		DoubanID    string    // 豆瓣编号，同 IMDbID，馒头支持
+		Categories  []string  // 分类 id，不为空时代替 MediaType 对应的分类，Discuz 为版块 id，只在有关键字时使用
+		Promotion   Promotion // 促销状态，站点不支持时按下载、上传系数过滤搜索结果
+		MinSize     int64     // 最小体积，单位字节，<= 0 时不限制，站点不支持时过滤搜索结果
+		MaxSize     int64     // 最大体积，单位字节，<= 0 时不限制，站点不支持时过滤搜索结果
+		IncludeDead bool      // 是否包含断种，NexusPHP、馒头支持
+		SortBy      SortField // 排序字段，NexusPHP、馒头、Gazelle、UNIT3D 支持，Gazelle 不支持按标题排序
+		SortOrder   SortOrder // 排序方向，同 SortBy
 	}
 	Site struct {
-		Code      string
-		Name      string
-		UserId    string
-		Api       string
-		Domain    string
-		UserAgent string
-		Cookie    string
-		Headers   string
-		RssUrl    string
+		Code        string
+		Name        string
+		UserId      string
+		Api         string
+		Domain      string
+		UserAgent   string
+		Cookie      string
+		Headers     string
+		RssUrl      string
+		ApiToken    string // API 令牌，UNIT3D 等使用令牌认证接口的站点需要配置
+		AnnounceUrl string // tracker 地址，用于生成磁力链接，私有站点通常包含 passkey，为空时使用站点配置的 announce
+
+		// 网络配置，Timeout 作用于所有请求；
+		// Proxy、InsecureSkipVerify、Transport 作用于本包直接发起的 HTTP 请求，如 RSS、种子下载、UNIT3D 接口，
+		// 其余站点适配请求由 siteadapt 发起，siteadapt 不支持自定义 HTTP 客户端，这些请求不使用代理和 TLS 配置
+		Timeout            time.Duration     // 单次请求超时时间，<= 0 时不限制
+		Proxy              string            // 代理地址，支持 http、https、socks5，如 socks5://127.0.0.1:1080
+		InsecureSkipVerify bool              // 是否跳过 TLS 证书校验
+		Transport          http.RoundTripper // 自定义 Transport，优先于 Proxy 和 InsecureSkipVerify，可用于测试
+
+		registry *Registry // 所属注册表，为空时使用默认注册表
 	}
 	MediaType struct {
 		Code string
@@ -0,0 +1,331 @@
+package btsite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// u3dClient UNIT3D 客户端，种子相关使用 API 令牌请求 JSON 接口，用户信息从个人主页解析，
+	// 站点 UserId 需要配置为用户名
+	u3dClient struct {
+		site *Site
+		*NexusPHPClient
+	}
+	// u3dTorrent UNIT3D 接口返回的种子，JSON:API 格式
+	u3dTorrent struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Name           string   `json:"name"`
+			Category       string   `json:"category"`
+			Type           string   `json:"type"`
+			Resolution     string   `json:"resolution"`
+			Size           int64    `json:"size"`
+			Freeleech      string   `json:"freeleech"`
+			DoubleUpload   gzBool   `json:"double_upload"`
+			Seeders        int      `json:"seeders"`
+			Leechers       int      `json:"leechers"`
+			TimesCompleted int      `json:"times_completed"`
+			CreatedAt      string   `json:"created_at"`
+			DownloadLink   string   `json:"download_link"`
+			DetailsLink    string   `json:"details_link"`
+			Internal       gzBool   `json:"internal"`
+			Featured       gzBool   `json:"featured"`
+			Refundable     gzBool   `json:"refundable"`
+			Keywords       []string `json:"keywords"`
+			InfoHash       string   `json:"info_hash"`
+			ImdbID         any      `json:"imdb_id"` // 数字或字符串，0 为未知
+			Meta           struct {
+				Poster string `json:"poster"`
+			} `json:"meta"`
+		} `json:"attributes"`
+	}
+	u3dTorrentList struct {
+		Data []u3dTorrent `json:"data"`
+	}
+	u3dTorrentResource struct {
+		Data u3dTorrent `json:"data"`
+	}
+)
+
+const (
+	requestIdU3DTorrentsFilter requestId = "torrents_filter"
+	requestIdU3DTorrent        requestId = "torrent"
+)
+
+// UNIT3D 搜索的 sortField 参数
+var u3dSortFields = map[SortField]string{
+	SortByTitle:    "name",
+	SortByTime:     "created_at",
+	SortBySize:     "size",
+	SortByGrabs:    "times_completed",
+	SortBySeeders:  "seeders",
+	SortByLeechers: "leechers",
+}
+
+func (c *u3dClient) UserBasicInfo(ctx context.Context) (UserBasicInfo, error) {
+	var ud UserBasicInfo
+	path, err := c.profilePath()
+	if err != nil {
+		return ud, err
+	}
+	err = data(requestSiteParams{
+		ctx:   ctx,
+		site:  c.site,
+		reqId: requestIdUserBasicInfo,
+		path:  path,
+	}, &ud, nil)
+	if err != nil {
+		return ud, newError(c.site, err, "解析基础信息失败")
+	}
+	ud.ID = c.site.UserId
+	if ud.Ratio == 0 && ud.Downloaded > 0 {
+		ud.Ratio = float64(int(float64(ud.Uploaded)/float64(ud.Downloaded)*1000)) / 1000
+	}
+	return ud, nil
+}
+
+func (c *u3dClient) UserDetails(ctx context.Context) (UserDetails, error) {
+	var ud UserDetails
+	path, err := c.profilePath()
+	if err != nil {
+		return ud, err
+	}
+	err = data(requestSiteParams{
+		ctx:   ctx,
+		site:  c.site,
+		reqId: requestIdUserDetails,
+		path:  path,
+	}, &ud, nil)
+	if err != nil {
+		return ud, newError(c.site, err, "解析用户详情信息异常")
+	}
+	return ud, nil
+}
+
+func (c *u3dClient) Search(ctx context.Context, searchParams SearchParams) ([]SearchTorrent, error) {
+	site := c.site
+	// 不支持按豆瓣编号搜索
+	local := localFilter{promotion: true, douban: true}
+	if err := idSearchError(site, searchParams, local); err != nil {
+		return nil, err
+	}
+	sc, err := siteConfig(site)
+	if err != nil {
+		return nil, newError(site, err, "未获取到站点配置")
+	}
+	params := url.Values{
+		// UNIT3D 从 1 开始
+		"page":    {strconv.Itoa(searchParams.Page + 1)},
+		"perPage": {"100"},
+	}
+	if len(searchParams.Keyword) > 0 {
+		params.Set("name", searchParams.Keyword)
+	}
+	if id := normalizeIMDbID(searchParams.IMDbID); id != "" {
+		// UNIT3D 的 IMDb 编号为数字
+		params.Set("imdbId", strings.TrimLeft(strings.TrimPrefix(id, "tt"), "0"))
+	}
+	for _, id := range searchCategories(sc, searchParams) {
+		params.Add("categories[]", id)
+	}
+	if sortField, ok := u3dSortFields[searchParams.SortBy]; ok {
+		params.Set("sortField", sortField)
+		params.Set("sortDirection", "desc")
+		if searchParams.SortOrder == SortAsc {
+			params.Set("sortDirection", "asc")
+		}
+	}
+	var list u3dTorrentList
+	if err := c.api(ctx, requestIdU3DTorrentsFilter, "api/torrents/filter", params, &list); err != nil {
+		return nil, newError(site, err, "搜索异常")
+	}
+	loc := siteLocation(sc)
+	var searchTorrents []SearchTorrent
+	for _, t := range list.Data {
+		searchTorrents = append(searchTorrents, t.searchTorrent(loc))
+	}
+	return searchResult(ctx, searchParams, searchTorrents, local), nil
+}
+
+// SeedingStatistics UNIT3D 的接口不提供做种列表，返回 ErrUnsupported
+func (c *u3dClient) SeedingStatistics(ctx context.Context) (SeedingStatistics, error) {
+	return SeedingStatistics{}, unsupported(c.site, "做种统计")
+}
+
+// UnreadMessages UNIT3D 的接口不提供站内信，返回 ErrUnsupported
+func (c *u3dClient) UnreadMessages(ctx context.Context, detail bool) ([]Message, error) {
+	return nil, unsupported(c.site, "未读消息")
+}
+
+// LatestNotice UNIT3D 的接口不提供公告，返回 ErrUnsupported
+func (c *u3dClient) LatestNotice(ctx context.Context) (*Notice, error) {
+	return nil, unsupported(c.site, "公告")
+}
+
+func (c *u3dClient) MyHr(ctx context.Context) ([]HrTorrent, error) {
+	// 暂不支持
+	return nil, nil
+}
+
+func (c *u3dClient) SignIn(ctx context.Context) (SignInResult, error) {
+	ubi, err := c.UserBasicInfo(WithoutCache(ctx))
+	// 重定向到登录页时返回 ErrNotLoggedIn，ubi.IsLogin 为 false
+	if err != nil && !errors.Is(err, ErrNotLoggedIn) {
+		return SignInResult{}, err
+	}
+	if !ubi.IsLogin {
+		return SignInResult{
+			Code:    SignInCodeNeedLogin,
+			Message: "未登录",
+		}, nil
+	}
+	return SignInResult{
+		Code:    SignInCodeSuccess,
+		Message: "模拟登录成功",
+	}, nil
+}
+
+// GetDownloadUrl 搜索结果没有下载地址时，通过 API 令牌获取种子的下载地址
+func (c *u3dClient) GetDownloadUrl(ctx context.Context, torrent SearchTorrent) (string, error) {
+	if len(torrent.Enclosure) > 0 {
+		return torrent.Enclosure, nil
+	}
+	t, err := c.torrent(ctx, torrent.ID)
+	if err != nil {
+		return "", newError(c.site, err, "获取下载地址异常")
+	}
+	return t.Attributes.DownloadLink, nil
+}
+
+func (c *u3dClient) Details(ctx context.Context, id string) (TorrentDetail, error) {
+	t, err := c.torrent(ctx, id)
+	if err != nil {
+		var se *SiteError
+		if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+			return TorrentDetail{Absent: true}, nil
+		}
+		return TorrentDetail{}, newError(c.site, err, "获取详情异常")
+	}
+	downloadVolumeFactor := u3dDownloadVolumeFactor(t.Attributes.Freeleech)
+	return TorrentDetail{
+		Free:       downloadVolumeFactor == 0,
+		DoubleFree: downloadVolumeFactor == 0 && bool(t.Attributes.DoubleUpload),
+		PeerCount:  t.Attributes.Seeders,
+	}, nil
+}
+
+// torrent 获取单个种子
+func (c *u3dClient) torrent(ctx context.Context, id string) (u3dTorrent, error) {
+	var r u3dTorrentResource
+	err := c.api(ctx, requestIdU3DTorrent, "api/torrents/"+url.PathEscape(id), nil, &r)
+	return r.Data, err
+}
+
+// api 请求 UNIT3D JSON 接口，path 为相对站点域名的地址，API 令牌通过 Authorization 请求头发送，避免出现在请求地址和错误信息中
+func (c *u3dClient) api(ctx context.Context, reqId requestId, path string, params url.Values, output any) error {
+	if c.site.ApiToken == "" {
+		return &SiteError{Site: c.site.Code, RequestId: string(reqId), Kind: ErrNotLoggedIn, Err: errors.New("未配置 API 令牌")}
+	}
+	domain, err := SiteHelper.GetDomain(*c.site)
+	if err != nil {
+		return err
+	}
+	if path, err = JoinURL(domain, path); err != nil {
+		return err
+	}
+	b, err := fetch(requestSiteParams{
+		ctx:    ctx,
+		site:   c.site,
+		reqId:  reqId,
+		path:   path,
+		params: params,
+		headers: map[string]string{
+			"Authorization": "Bearer " + c.site.ApiToken,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, output); err != nil {
+		return &SiteError{Site: c.site.Code, RequestId: string(reqId), Kind: ErrParse, Err: err}
+	}
+	return nil
+}
+
+// profilePath 个人主页地址，UNIT3D 使用用户名作为用户 id
+func (c *u3dClient) profilePath() (string, error) {
+	if len(c.site.UserId) == 0 {
+		return "", newError(c.site, errors.New("未配置用户 id"), "获取个人主页异常")
+	}
+	domain, err := SiteHelper.GetDomain(*c.site)
+	if err != nil {
+		return "", err
+	}
+	return JoinURL(domain, "users/"+url.PathEscape(c.site.UserId))
+}
+
+func (t u3dTorrent) searchTorrent(loc *time.Location) SearchTorrent {
+	a := t.Attributes
+	uploadVolumeFactor := 1.0
+	if a.DoubleUpload {
+		uploadVolumeFactor = 2
+	}
+	var labels []string
+	for _, label := range []string{a.Type, a.Resolution} {
+		if len(label) > 0 {
+			labels = append(labels, label)
+		}
+	}
+	if a.Internal {
+		labels = append(labels, "Internal")
+	}
+	return SearchTorrent{
+		ID:                   t.ID,
+		Category:             a.Category,
+		Title:                a.Name,
+		PageURL:              a.DetailsLink,
+		Enclosure:            a.DownloadLink,
+		Grabs:                a.TimesCompleted,
+		Seeders:              a.Seeders,
+		Leechers:             a.Leechers,
+		Size:                 a.Size,
+		DownloadVolumeFactor: u3dDownloadVolumeFactor(a.Freeleech),
+		UploadVolumeFactor:   uploadVolumeFactor,
+		PubDate:              parseTime(a.CreatedAt, loc),
+		PubDateRaw:           a.CreatedAt,
+		Labels:               labels,
+		InfoHash:             normalizeInfoHash(a.InfoHash),
+		IMDbID:               u3dImdbID(a.ImdbID),
+		PosterURL:            a.Meta.Poster,
+	}
+}
+
+// u3dDownloadVolumeFactor 将 freeleech 百分比转换为下载系数，如 100% 为 0，25% 为 0.75
+func u3dDownloadVolumeFactor(freeleech string) float64 {
+	percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(freeleech, "%")), 64)
+	if err != nil || percent <= 0 {
+		return 1
+	}
+	if percent >= 100 {
+		return 0
+	}
+	return float64(int(100-percent)) / 100
+}
+
+// u3dImdbID imdb_id 为不带 tt 前缀的数字，部分版本为字符串
+func u3dImdbID(v any) string {
+	switch id := v.(type) {
+	case float64:
+		return normalizeIMDbID(strconv.FormatInt(int64(id), 10))
+	case string:
+		return normalizeIMDbID(id)
+	}
+	return ""
+}
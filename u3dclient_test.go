@@ -0,0 +1,197 @@
+package btsite_test
+
+import (
+	"context"
+	"errors"
+	"github.com/heibizi/go-btsite"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newUNIT3DClient(t *testing.T) (btsite.ClientContext, *httptest.Server) {
+	return newFixtureClient(t, "UNIT3D", "unit3d", func(r *http.Request) string {
+		switch r.URL.Path {
+		case "/users/tester":
+			return "profile.html"
+		}
+		if r.Header.Get("Authorization") != "Bearer fixture-token" || r.URL.Query().Has("api_token") {
+			return ""
+		}
+		switch r.URL.Path {
+		case "/api/torrents/filter":
+			return "filter.json"
+		case "/api/torrents/5001":
+			return "torrent.json"
+		}
+		return ""
+	}, func(site *btsite.Site) {
+		site.ApiToken = "fixture-token"
+		site.UserId = "tester"
+	})
+}
+
+func TestUNIT3DUserBasicInfo(t *testing.T) {
+	c, _ := newUNIT3DClient(t)
+	info, err := c.UserBasicInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := btsite.UserBasicInfo{
+		IsLogin:    true,
+		ID:         "tester",
+		Name:       "tester",
+		Ratio:      3,
+		Uploaded:   1649267441664,
+		Downloaded: 549755813888,
+		Bonus:      12345.67,
+	}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestUNIT3DUserDetails(t *testing.T) {
+	c, _ := newUNIT3DClient(t)
+	details, err := c.UserDetails(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	joinAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.Local).Unix()
+	if details.Level != "Power User" || details.JoinAt != joinAt {
+		t.Errorf("got %+v, want level Power User, join at %d", details, joinAt)
+	}
+}
+
+func TestUNIT3DSearch(t *testing.T) {
+	c, _ := newUNIT3DClient(t)
+	torrents, err := c.Search(context.Background(), btsite.SearchParams{Keyword: "The Matrix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 2 {
+		t.Fatalf("got %d torrents, want 2", len(torrents))
+	}
+	free := torrents[0]
+	if free.ID != "5001" || free.DownloadVolumeFactor != 0 || free.UploadVolumeFactor != 2 {
+		t.Errorf("got %+v", free)
+	}
+	if free.Size != 10737418240 || free.Seeders != 42 || free.Grabs != 128 {
+		t.Errorf("got %+v", free)
+	}
+	if len(free.Labels) != 3 || free.Labels[2] != "Internal" {
+		t.Errorf("labels = %v", free.Labels)
+	}
+	if free.IMDbID != "tt0133093" || free.InfoHash != "e2bf155b08156d14fa8014b8556bcdc7c7b578a0" || free.PosterURL == "" {
+		t.Errorf("got %+v", free)
+	}
+	if partial := torrents[1]; partial.DownloadVolumeFactor != 0.75 || partial.UploadVolumeFactor != 1 {
+		t.Errorf("got %+v", partial)
+	}
+}
+
+func TestUNIT3DSearchParams(t *testing.T) {
+	var query url.Values
+	c, _ := newFixtureClient(t, "UNIT3D", "unit3d", func(r *http.Request) string {
+		query = r.URL.Query()
+		return "filter.json"
+	}, func(site *btsite.Site) {
+		site.ApiToken = "fixture-token"
+	})
+	ctx := context.Background()
+	_, err := c.Search(ctx, btsite.SearchParams{
+		Keyword:    "The Matrix",
+		IMDbID:     "tt0133093",
+		Categories: []string{"3", "4"},
+		SortBy:     btsite.SortByGrabs,
+		SortOrder:  btsite.SortAsc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{
+		"page": {"1"}, "perPage": {"100"}, "name": {"The Matrix"}, "imdbId": {"133093"},
+		"categories[]": {"3", "4"}, "sortField": {"times_completed"}, "sortDirection": {"asc"},
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("query = %v, want %v", query, want)
+	}
+	if _, err := c.Search(ctx, btsite.SearchParams{DoubanID: "1291843"}); !errors.Is(err, btsite.ErrUnsupportedSearch) {
+		t.Errorf("Search(douban) err = %v, want ErrUnsupportedSearch", err)
+	}
+}
+
+func TestUNIT3DGetDownloadUrl(t *testing.T) {
+	c, _ := newUNIT3DClient(t)
+	ctx := context.Background()
+	torrents, err := c.Search(ctx, btsite.SearchParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		torrent btsite.SearchTorrent
+		want    string
+	}{
+		{torrents[0], "https://unit3d.example/torrent/download/5001.token"},
+		{torrents[1], "https://unit3d.example/torrent/download/5002.token"},
+	} {
+		got, err := c.GetDownloadUrl(ctx, tc.torrent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("GetDownloadUrl(%s) = %q, want %q", tc.torrent.ID, got, tc.want)
+		}
+	}
+}
+
+func TestUNIT3DDetails(t *testing.T) {
+	c, _ := newUNIT3DClient(t)
+	ctx := context.Background()
+	detail, err := c.Details(ctx, "5001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (btsite.TorrentDetail{Free: true, DoubleFree: true, PeerCount: 42}); detail != want {
+		t.Errorf("got %+v, want %+v", detail, want)
+	}
+	detail, err = c.Details(ctx, "404")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !detail.Absent {
+		t.Errorf("got %+v, want absent", detail)
+	}
+}
+
+func TestUNIT3DMissingApiToken(t *testing.T) {
+	var hits atomic.Int32
+	c, _ := newFixtureClient(t, "UNIT3D", "unit3d", func(r *http.Request) string {
+		hits.Add(1)
+		return "filter.json"
+	})
+	if _, err := c.Search(context.Background(), btsite.SearchParams{Keyword: "movie"}); !errors.Is(err, btsite.ErrNotLoggedIn) {
+		t.Errorf("Search() err = %v, want ErrNotLoggedIn", err)
+	}
+	if got := hits.Load(); got != 0 {
+		t.Errorf("hits = %d, want no request without API token", got)
+	}
+}
+
+func TestUNIT3DUnsupported(t *testing.T) {
+	c, _ := newUNIT3DClient(t)
+	ctx := context.Background()
+	if _, err := c.SeedingStatistics(ctx); !errors.Is(err, btsite.ErrUnsupported) {
+		t.Errorf("SeedingStatistics() err = %v, want ErrUnsupported", err)
+	}
+	if _, err := c.UnreadMessages(ctx, false); !errors.Is(err, btsite.ErrUnsupported) {
+		t.Errorf("UnreadMessages() err = %v, want ErrUnsupported", err)
+	}
+	if _, err := c.LatestNotice(ctx); !errors.Is(err, btsite.ErrUnsupported) {
+		t.Errorf("LatestNotice() err = %v, want ErrUnsupported", err)
+	}
+}
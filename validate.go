@@ -0,0 +1,348 @@
+package btsite
+
+import (
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+	"github.com/heibizi/go-siteadapt"
+	"github.com/tidwall/gjson"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type (
+	// IssueLevel 问题级别
+	IssueLevel string
+	// Issue 配置检查发现的问题
+	Issue struct {
+		Level     IssueLevel // 级别
+		Config    string     // 站点唯一标识，公共配置为 commons/架构
+		RequestId string     // 请求 id，站点级别的问题为空
+		Field     string     // 字段，多级字段用 . 分隔
+		Message   string     // 问题描述
+	}
+	// schemaRequests 系统架构的请求定义，required 为必需的请求，known 为客户端使用的其他请求
+	schemaRequests struct {
+		required []requestId
+		known    []requestId
+	}
+)
+
+const (
+	IssueError   IssueLevel = "error"   // 错误，站点无法正常使用
+	IssueWarning IssueLevel = "warning" // 警告，可能是配置错误
+)
+
+// 所有架构的客户端都可能使用的请求，NexusPHPClient 的默认实现
+var commonRequests = []requestId{requestIdFavicon, requestIdUserBasicInfo, requestIdUserDetails, requestIdSearch,
+	requestIdSeedingStatistics, requestIdMyHr, requestIdUnreadMessages, requestIdUnreadMessageDetail,
+	requestIdMarkAsRead, requestIdLatestNotice, requestIdSignIn, requestIdDetails}
+
+// 内置架构的请求定义，自定义架构不检查请求 id
+var schemaRequestDefinitions = map[siteSchema]schemaRequests{
+	siteSchemaNexusPHP: {
+		required: []requestId{requestIdUserBasicInfo, requestIdSearch},
+	},
+	siteSchemaMTorrent: {
+		required: []requestId{requestIdMTProfile, requestIdMTMsgNotifyStatistic, requestIdSearch},
+		known: []requestId{requestIdMTMyPeerStatus, requestIdMTUserTorrentList, requestIdMTSysRoleList,
+			requestIdMTGenDLToken, requestIdMarkAsRead},
+	},
+	siteSchemaGazelle: {
+		required: []requestId{requestIdGZIndex, requestIdGZBrowse, requestIdGZUser},
+		known:    []requestId{requestIdGZInbox, requestIdGZAnnouncements, requestIdGZTorrent, requestIdGZNotifications},
+	},
+	siteSchemaUNIT3D: {
+		required: []requestId{requestIdUserBasicInfo},
+	},
+	siteSchemaDiscuz: {
+		required: []requestId{requestIdUserBasicInfo, requestIdSearch, requestIdDZSignInPage, requestIdSignIn},
+		known:    []requestId{requestIdDZLatestThreads},
+	},
+}
+
+// siteadapt 支持的过滤器名称，siteadapt 没有导出过滤器列表，只检查名称，参数由 siteadapt 执行时处理，
+// TestKnownFilters 将 README 的示例交给 siteadapt 执行，升级 siteadapt 时同步修改
+var knownFilters = map[string]bool{
+	"append_left":   true,
+	"blank":         true,
+	"byte_size":     true,
+	"case":          true,
+	"constant":      true,
+	"default_value": true,
+	"eq":            true,
+	"not_blank":     true,
+	"querystring":   true,
+	"regex":         true,
+	"replace":       true,
+	"re_search":     true,
+	"split":         true,
+	"strip":         true,
+	"timestamp":     true,
+}
+
+func (i Issue) String() string {
+	location := i.Config
+	if i.RequestId != "" {
+		location += " " + i.RequestId
+	}
+	if i.Field != "" {
+		location += "." + i.Field
+	}
+	return fmt.Sprintf("[%s] %s: %s", i.Level, location, i.Message)
+}
+
+// Validate 静态检查站点适配配置，返回发现的问题，公共配置在前，站点按配置顺序。检查内容：
+// 未知的请求 id、架构必需的请求定义、过滤器名称、re_search 和 regex 的正则表达式、
+// CSS、XPath、JsonPath 选择器、fields_ref 引用、重复的站点 id、schema 和 reuse_schema 对应的公共配置、timezone
+func Validate(cfg AdaptCfg) []Issue {
+	v := &validator{}
+	for _, schema := range sortedKeys(cfg.Common) {
+		common := cfg.Common[schema]
+		v.requests("commons/"+schema, siteSchema(schema), common.RequestDefinitions, common.RequestDefinitions, false)
+	}
+	ids := make(map[string]bool)
+	for _, sc := range cfg.Configs {
+		if ids[sc.ID] {
+			v.add(IssueError, sc.ID, "", "", "站点 id 重复")
+		}
+		ids[sc.ID] = true
+		if _, ok := clientFactory(sc.Schema); !ok {
+			v.add(IssueError, sc.ID, "", "", fmt.Sprintf("不支持的系统架构: %q", sc.Schema))
+		}
+		if _, err := loadLocation(sc.Timezone); err != nil {
+			v.add(IssueError, sc.ID, "", "timezone", fmt.Sprintf("无效的时区 %q: %v", sc.Timezone, err))
+		}
+		if sc.ReuseSchema != "" {
+			if _, ok := cfg.Common[sc.ReuseSchema]; !ok {
+				v.add(IssueError, sc.ID, "", "", fmt.Sprintf("reuse_schema 对应的公共配置不存在: %s", sc.ReuseSchema))
+			}
+		}
+		schema := siteSchema(sc.Schema)
+		if _, ok := cfg.Common[sc.Schema]; !ok && sc.ReuseSchema != "" {
+			schema = siteSchema(sc.ReuseSchema)
+		}
+		v.requests(sc.ID, schema, sc.RequestDefinitions, ownRequests(cfg, sc), true)
+		if sc.Required.SignIn {
+			if _, ok := sc.RequestDefinitions[string(requestIdSignIn)]; !ok {
+				v.add(IssueError, sc.ID, string(requestIdSignIn), "", "sign_in_required 为 true，缺少签到请求定义")
+			}
+		}
+	}
+	return v.issues
+}
+
+// ownRequests 去掉从公共配置原样继承的请求定义，公共配置的问题只在公共配置中报告一次
+func ownRequests(cfg AdaptCfg, sc Config) map[string]siteadapt.RequestDefinition {
+	common, ok := cfg.Common[sc.Schema]
+	if !ok {
+		common, ok = cfg.Common[sc.ReuseSchema]
+	}
+	if !ok {
+		return sc.RequestDefinitions
+	}
+	rds := make(map[string]siteadapt.RequestDefinition, len(sc.RequestDefinitions))
+	for name, rd := range sc.RequestDefinitions {
+		if commonRd, exists := common.RequestDefinitions[name]; exists && reflect.DeepEqual(rd, commonRd) {
+			continue
+		}
+		rds[name] = rd
+	}
+	return rds
+}
+
+type validator struct {
+	issues []Issue
+}
+
+func (v *validator) add(level IssueLevel, config string, reqId string, field string, message string) {
+	v.issues = append(v.issues, Issue{Level: level, Config: config, RequestId: reqId, Field: field, Message: message})
+}
+
+// requests 检查请求定义，rds 为合并公共配置后的全部请求定义，checked 为需要检查内容的请求定义，
+// site 为 true 时检查架构必需的请求
+func (v *validator) requests(config string, schema siteSchema, rds map[string]siteadapt.RequestDefinition,
+	checked map[string]siteadapt.RequestDefinition, site bool) {
+	sr, builtin := schemaRequestDefinitions[schema]
+	if builtin && site {
+		for _, reqId := range sr.required {
+			if _, ok := rds[string(reqId)]; !ok {
+				v.add(IssueError, config, string(reqId), "", "缺少必需的请求定义")
+			}
+		}
+	}
+	for _, name := range sortedKeys(checked) {
+		if builtin && !containsRequestId(commonRequests, name) && !containsRequestId(sr.known, name) &&
+			!containsRequestId(sr.required, name) {
+			v.add(IssueWarning, config, name, "", fmt.Sprintf("%s 架构不使用该请求 id", schema))
+		}
+		v.request(config, name, checked[name], rds)
+	}
+	if err := checkSearchFields(checked); err != nil {
+		v.add(IssueError, config, string(requestIdSearch), "", err.Error())
+	}
+}
+
+// request 检查单个请求定义的选择器、过滤器和 fields_ref
+func (v *validator) request(config string, reqId string, rd siteadapt.RequestDefinition, rds map[string]siteadapt.RequestDefinition) {
+	if rd.FieldsRef != "" {
+		if _, ok := rds[rd.FieldsRef]; !ok {
+			v.add(IssueError, config, reqId, "fields_ref", fmt.Sprintf("引用的请求定义不存在: %s", rd.FieldsRef))
+		}
+	}
+	if rd.List != nil {
+		v.selector(config, reqId, "list", rd.Parser, rd.List.Selector)
+	}
+	if rd.Field != nil {
+		v.field(config, reqId, "field", rd.Parser, *rd.Field)
+	}
+	for _, name := range sortedKeys(rd.Fields) {
+		v.field(config, reqId, name, rd.Parser, rd.Fields[name])
+	}
+}
+
+// field 检查字段的选择器和过滤器，any 中的字段递归检查
+func (v *validator) field(config string, reqId string, path string, parser string, field siteadapt.Field) {
+	v.selector(config, reqId, path, parser, field.Selector)
+	for i, filter := range field.Filters {
+		v.filter(config, reqId, fmt.Sprintf("%s.filters[%d]", path, i), filter)
+	}
+	for i, sub := range field.Any {
+		v.field(config, reqId, fmt.Sprintf("%s.any[%d]", path, i), parser, sub)
+	}
+}
+
+// selector 按解析器编译选择器，空选择器不检查
+func (v *validator) selector(config string, reqId string, path string, parser string, selector string) {
+	if selector == "" {
+		return
+	}
+	var err error
+	switch parser {
+	case "CssSelector":
+		_, err = cascadia.Compile(selector)
+	case "XPath":
+		_, err = xpath.Compile(selector)
+	case "JsonPath":
+		err = checkJsonPath(selector)
+	}
+	if err != nil {
+		v.add(IssueError, config, reqId, path+".selector", fmt.Sprintf("%s 选择器不合法: %q: %v", parser, selector, err))
+	}
+}
+
+// filter 检查过滤器名称和 re_search、regex 的正则表达式，不检查参数个数
+func (v *validator) filter(config string, reqId string, path string, filter siteadapt.Filter) {
+	name := filter.Name
+	if !knownFilters[name] {
+		v.add(IssueError, config, reqId, path, fmt.Sprintf("未知的过滤器: %q", name))
+		return
+	}
+	if name != "re_search" && name != "regex" {
+		return
+	}
+	expr := filter.Args
+	if list, ok := expr.([]any); ok && len(list) > 0 {
+		expr = list[0]
+	}
+	s, ok := expr.(string)
+	if !ok {
+		v.add(IssueError, config, reqId, path, fmt.Sprintf("过滤器 %s 的正则表达式必须为字符串", name))
+		return
+	}
+	if _, err := regexp.Compile(s); err != nil {
+		v.add(IssueError, config, reqId, path, fmt.Sprintf("过滤器 %s 的正则表达式不合法: %v", name, err))
+	}
+}
+
+// checkJsonPath 检查 gjson 路径：括号和引号是否匹配，( 只能用于 #( 查询，路径分隔符 . 和 | 之间不能为空，
+// @ 开头的修饰符需要已注册，不检查多路径和查询条件的内部
+func checkJsonPath(path string) error {
+	if strings.HasPrefix(path, "..") {
+		// JSON Lines
+		path = path[2:]
+	}
+	var stack []byte
+	var quote bool
+	start := 0
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if quote {
+			if c == '"' {
+				quote = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			quote = true
+		case '(':
+			if i == 0 || path[i-1] != '#' {
+				return fmt.Errorf("位置 %d 的 ( 不是 #( 查询", i)
+			}
+			stack = append(stack, c)
+		case '[', '{':
+			stack = append(stack, c)
+		case ')', ']', '}':
+			open := map[byte]byte{')': '(', ']': '[', '}': '{'}[c]
+			if len(stack) == 0 || stack[len(stack)-1] != open {
+				return fmt.Errorf("位置 %d 的 %c 不匹配", i, c)
+			}
+			stack = stack[:len(stack)-1]
+		case '.', '|':
+			if len(stack) > 0 {
+				continue
+			}
+			if err := checkJsonPathComponent(path[start:i]); err != nil {
+				return fmt.Errorf("位置 %d: %w", start, err)
+			}
+			start = i + 1
+		}
+	}
+	if quote || len(stack) > 0 {
+		return fmt.Errorf("括号或引号未闭合")
+	}
+	if err := checkJsonPathComponent(path[start:]); err != nil {
+		return fmt.Errorf("位置 %d: %w", start, err)
+	}
+	return nil
+}
+
+// checkJsonPathComponent 检查路径分隔符之间的部分，不能为空，修饰符需要已注册
+func checkJsonPathComponent(component string) error {
+	if component == "" {
+		return fmt.Errorf("路径为空")
+	}
+	if !strings.HasPrefix(component, "@") {
+		return nil
+	}
+	name, _, _ := strings.Cut(component[1:], ":")
+	if !gjson.ModifierExists(name, nil) {
+		return fmt.Errorf("未知的修饰符: @%s", name)
+	}
+	return nil
+}
+
+func containsRequestId(ids []requestId, name string) bool {
+	for _, id := range ids {
+		if string(id) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
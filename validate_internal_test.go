@@ -0,0 +1,100 @@
+package btsite
+
+import (
+	"github.com/heibizi/go-siteadapt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// filterSamples README filter 说明中的示例，knownFilters 中的每个过滤器至少有一个
+var filterSamples = []siteadapt.Filter{
+	{Name: "append_left", Args: "str"},
+	{Name: "blank"},
+	{Name: "blank", Args: "if True"},
+	{Name: "blank", Args: []any{"if True", "if False"}},
+	{Name: "byte_size"},
+	{Name: "case", Args: map[string]any{"k1": "v1", "*": "defaultValue"}},
+	{Name: "constant", Args: "str"},
+	{Name: "default_value", Args: "0"},
+	{Name: "eq", Args: "str"},
+	{Name: "eq", Args: []any{"str", "if True", "if False"}},
+	{Name: "not_blank"},
+	{Name: "not_blank", Args: "if True"},
+	{Name: "not_blank", Args: []any{"if True", "if False"}},
+	{Name: "querystring", Args: "str"},
+	{Name: "regex", Args: "regex"},
+	{Name: "regex", Args: []any{"regex", "if True", "if False"}},
+	{Name: "replace", Args: []any{"separator", "old", "new"}},
+	{Name: "re_search", Args: []any{"上传量: (.*)", float64(1)}},
+	{Name: "split", Args: []any{" ", float64(-1)}},
+	{Name: "strip"},
+	{Name: "timestamp"},
+}
+
+// TestKnownFilters knownFilters 与 siteadapt 的过滤器保持一致：示例通过检查，且 siteadapt 执行时不报错
+func TestKnownFilters(t *testing.T) {
+	sampled := make(map[string]bool)
+	fields := make(map[string]siteadapt.Field)
+	for i, sample := range filterSamples {
+		sampled[sample.Name] = true
+		v := &validator{}
+		v.filter("test", "test", "f", sample)
+		if len(v.issues) > 0 {
+			t.Errorf("filter(%+v) = %v, want no issue", sample, v.issues)
+		}
+		fields["f"+strconv.Itoa(i)] = siteadapt.Field{Selector: "p", Filters: []siteadapt.Filter{sample}}
+	}
+	for name := range knownFilters {
+		if !sampled[name] {
+			t.Errorf("过滤器 %s 没有示例", name)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><body><p>上传量: 1 TB</p></body></html>"))
+	}))
+	defer srv.Close()
+	r := NewRegistry(&AdaptCfg{Configs: []Config{{
+		Config: siteadapt.Config{ID: "filters", Domain: srv.URL, RequestDefinitions: map[string]siteadapt.RequestDefinition{
+			string(requestIdUserDetails): {Parser: "CssSelector", Path: "/", Fields: fields},
+		}},
+		Schema: string(siteSchemaNexusPHP),
+	}}})
+	var m map[string]any
+	err := data(requestSiteParams{
+		site:  &Site{Code: "filters", Name: "filters", registry: r},
+		reqId: requestIdUserDetails,
+	}, &m, nil)
+	if err != nil {
+		t.Errorf("siteadapt 执行过滤器示例异常: %v", err)
+	}
+}
+
+func TestCheckJsonPath(t *testing.T) {
+	cases := []struct {
+		path string
+		ok   bool
+	}{
+		{"data.list", true},
+		{"data.list.#.name", true},
+		{`data.#(name=="a.b").id`, true},
+		{`data.friends.#(last=="Murphy")#|#.first`, true},
+		{"data.list|@reverse", true},
+		{`data.{id,name}`, true},
+		{`data\.list`, true},
+		{"..#.name", true},
+		{"data.list[0", false},
+		{"data.(name)", false},
+		{"data..list", false},
+		{"data.list.", false},
+		{"data|", false},
+		{"data.@unknown", false},
+		{`data.#(name=="a`, false},
+	}
+	for _, c := range cases {
+		if err := checkJsonPath(c.path); (err == nil) != c.ok {
+			t.Errorf("checkJsonPath(%q) = %v, want ok %v", c.path, err, c.ok)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package btsite_test
+
+import (
+	"github.com/heibizi/go-btsite"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidate(t *testing.T) {
+	conf, err := btsite.LoadConfig(fstest.MapFS{
+		"commons/NexusPHP.json": {Data: []byte(`{"id": "NexusPHP", "requests": {
+			"user_basic_info": {"parser": "CssSelector", "fields": {"uid": {"selector": "a[href"}}}}}`)},
+		"sites/a.json": {Data: []byte(`{"id": "a", "name": "A", "schema": "NexusPHP", "requests": {
+			"search": {"parser": "CssSelector", "list": {"selector": "table.torrents > tr"}, "fields": {
+				"title": {"selector": "a", "filters": [{"name": "upper"}]},
+				"size": {"selector": "td", "filters": [{"name": "re_search", "args": ["(", 1]}, {"name": "replace", "args": ["a"]}]}}},
+			"details": {"parser": "XPath", "field": {"selector": "//div["}},
+			"my_hr": {"parser": "JsonPath", "fields_ref": "missing", "list": {"selector": "data.list[0"}},
+			"bogus": {"parser": "None"}}}`)},
+		"sites/a2.json": {Data: []byte(`{"id": "a", "name": "A2", "schema": "NexusPHP"}`)},
+		"sites/b.json":  {Data: []byte(`{"id": "b", "name": "B", "schema": "Unknown", "reuse_schema": "Missing"}`)},
+		"sites/c.json":  {Data: []byte(`{"id": "c", "name": "C", "schema": "Gazelle", "timezone": "Mars/Olympus"}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := btsite.Validate(*conf)
+	want := []struct {
+		level  btsite.IssueLevel
+		config string
+		reqId  string
+		field  string
+	}{
+		{btsite.IssueError, "commons/NexusPHP", "user_basic_info", "uid.selector"},
+		{btsite.IssueError, "a", "search", "title.filters[0]"},
+		{btsite.IssueError, "a", "search", "size.filters[0]"},
+		{btsite.IssueError, "a", "details", "field.selector"},
+		{btsite.IssueError, "a", "my_hr", "fields_ref"},
+		{btsite.IssueError, "a", "my_hr", "list.selector"},
+		{btsite.IssueWarning, "a", "bogus", ""},
+		{btsite.IssueError, "a", "", ""},
+		{btsite.IssueError, "b", "", ""},
+		{btsite.IssueError, "c", "", "timezone"},
+		{btsite.IssueError, "c", "index", ""},
+		{btsite.IssueError, "c", "browse", ""},
+		{btsite.IssueError, "c", "user", ""},
+	}
+	for _, w := range want {
+		found := false
+		for _, issue := range issues {
+			if issue.Level == w.level && issue.Config == w.config && issue.RequestId == w.reqId && issue.Field == w.field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Validate() 缺少问题 %v, got:\n%v", w, issues)
+		}
+	}
+	for _, issue := range issues {
+		if issue.Config == "a" && issue.RequestId == "user_basic_info" {
+			t.Errorf("继承的公共配置重复报告: %v", issue)
+		}
+	}
+	var b int
+	for _, issue := range issues {
+		if issue.Config == "b" {
+			b++
+		}
+	}
+	if b != 2 {
+		t.Errorf("站点 b 的问题 = %d, want 2 (架构、reuse_schema)", b)
+	}
+}
+
+// TestValidateCommons 内置的公共配置不应有问题
+func TestValidateCommons(t *testing.T) {
+	fsys := fstest.MapFS{"sites": {Mode: fs.ModeDir}}
+	err := fs.WalkDir(os.DirFS("configs"), "commons", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		data, err := fs.ReadFile(os.DirFS("configs"), path)
+		fsys[path] = &fstest.MapFile{Data: data}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := btsite.LoadConfig(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range btsite.Validate(*conf) {
+		t.Error(issue)
+	}
+}
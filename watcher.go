@@ -0,0 +1,115 @@
+package btsite
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultWatchInterval = 2 * time.Second
+
+type (
+	// WatchOptions 配置文件监听参数
+	WatchOptions struct {
+		Interval time.Duration // 轮询间隔，<= 0 时默认为 2 秒
+	}
+	// ReloadEvent 配置重新加载事件
+	ReloadEvent struct {
+		Time    time.Time // 重新加载时间
+		Applied bool      // 新配置是否已生效
+		Err     error     // 加载异常，有异常时保留原配置，通常为 ConfigErrors
+	}
+	// fileStat 文件快照，用于判断文件是否变化
+	fileStat struct {
+		modTime time.Time
+		size    int64
+	}
+)
+
+// Watch 监听 path 下 commons、sites 目录中 json 文件的增删改，变化后重新加载并合并配置。
+// 新配置没有任何异常时才会原子替换注册表的配置，否则保留原配置，结果通过返回的 channel 通知。
+// channel 只缓存最新的一个事件，调用方不读取时不影响后续的重新加载。ctx 结束后停止监听并关闭 channel。
+func (r *Registry) Watch(ctx context.Context, path string, opts WatchOptions) <-chan ReloadEvent {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	events := make(chan ReloadEvent, 1)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := snapshot(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current := snapshot(path)
+			if sameSnapshot(last, current) {
+				continue
+			}
+			last = current
+			event := r.reload(path)
+			// 未读取的旧事件被替换为最新事件，只有当前 goroutine 发送，清空后发送不会阻塞
+			select {
+			case events <- event:
+			default:
+				select {
+				case <-events:
+				default:
+				}
+				events <- event
+			}
+		}
+	}()
+	return events
+}
+
+// reload 重新加载配置，无异常时替换当前配置
+func (r *Registry) reload(path string) ReloadEvent {
+	event := ReloadEvent{Time: time.Now()}
+	cfg, err := LoadConfig(os.DirFS(path))
+	if err != nil {
+		event.Err = err
+		return event
+	}
+	r.SetConfig(cfg)
+	event.Applied = true
+	return event
+}
+
+// snapshot 获取配置目录下所有 json 文件的修改时间和大小
+func snapshot(path string) map[string]fileStat {
+	stats := make(map[string]fileStat)
+	for _, dir := range []string{"commons", "sites"} {
+		_ = filepath.WalkDir(filepath.Join(path, dir), func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(p) != ".json" {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			stats[p] = fileStat{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+	return stats
+}
+
+func sameSnapshot(a, b map[string]fileStat) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, sa := range a {
+		sb, ok := b[p]
+		if !ok || !sa.modTime.Equal(sb.modTime) || sa.size != sb.size {
+			return false
+		}
+	}
+	return true
+}
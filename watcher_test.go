@@ -0,0 +1,74 @@
+package btsite_test
+
+import (
+	"context"
+	"github.com/heibizi/go-btsite"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchKeepsConfigOnBrokenEdit(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"commons", "sites"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &btsite.AdaptCfg{Configs: []btsite.Config{{Schema: "NexusPHP"}}}
+	r := btsite.NewRegistry(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := r.Watch(ctx, dir, btsite.WatchOptions{Interval: 10 * time.Millisecond})
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "sites", "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Applied || event.Err == nil {
+			t.Errorf("event = %+v, want not applied with error", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("no reload event")
+	}
+	if got := len(r.AllSupportedSites()); got != 1 {
+		t.Errorf("AllSupportedSites() = %d, want 1", got)
+	}
+}
+
+func TestWatchDoesNotBlockWithoutReader(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"commons", "sites"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := btsite.NewRegistry(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := r.Watch(ctx, dir, btsite.WatchOptions{Interval: 10 * time.Millisecond})
+	time.Sleep(50 * time.Millisecond)
+	// 不读取 events，连续修改配置，每次修改都应生效
+	for i, code := range []string{"a", "b", "c"} {
+		data := []byte(`{"id": "` + code + `", "name": "` + code + `", "schema": "NexusPHP"}`)
+		if err := os.WriteFile(filepath.Join(dir, "sites", code+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for len(r.AllSupportedSites()) != i+1 {
+			if ctx.Err() != nil {
+				t.Fatalf("第 %d 次修改未生效", i+1)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	select {
+	case event := <-events:
+		if !event.Applied {
+			t.Errorf("event = %+v, want applied", event)
+		}
+	default:
+		t.Error("没有缓存最新的事件")
+	}
+}